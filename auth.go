@@ -0,0 +1,162 @@
+package httpclient
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Authenticator adds credentials to requests, e.g. an OAuth2 token or a
+// request signature.
+type Authenticator interface {
+	// Authenticate is called before every attempt of the request.
+	Authenticate(req *http.Request) error
+
+	// Challenge is called for 401 responses. attempt starts with 1. If it
+	// returns true the request is authenticated and sent again.
+	Challenge(req *http.Request, res *http.Response, attempt int) (retry bool, err error)
+}
+
+// statusChallenger is implemented by authenticators that are also challenged
+// for other statuses than 401.
+type statusChallenger interface {
+	challengeStatus(status int) bool
+}
+
+func isAuthChallenge(authenticator Authenticator, status int) bool {
+	if sc, ok := authenticator.(statusChallenger); ok {
+		return sc.challengeStatus(status)
+	}
+
+	return status == http.StatusUnauthorized
+}
+
+type authChallenge struct {
+	Scheme string
+	Params map[string]string
+}
+
+// parseAuthChallenges parses WWW-Authenticate header values (RFC 9110). Auth
+// param names and schemes are lowercased.
+func parseAuthChallenges(header string) (challenges []authChallenge) {
+	s := header
+
+	skipSpace := func() {
+		s = strings.TrimLeft(s, " \t")
+	}
+
+	readToken := func() string {
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ',' || r == '='
+		})
+
+		if i < 0 {
+			i = len(s)
+		}
+
+		token := s[:i]
+		s = s[i:]
+
+		return token
+	}
+
+	readQuoted := func() string {
+		var b strings.Builder
+
+		// skip the opening quote
+		s = s[1:]
+
+		for len(s) > 0 {
+			c := s[0]
+			s = s[1:]
+
+			if c == '"' {
+				break
+			}
+
+			if c == '\\' && len(s) > 0 {
+				c = s[0]
+				s = s[1:]
+			}
+
+			b.WriteByte(c)
+		}
+
+		return b.String()
+	}
+
+	for {
+		s = strings.TrimLeft(s, " \t,")
+
+		if s == "" {
+			return challenges
+		}
+
+		challenge := authChallenge{
+			Scheme: strings.ToLower(readToken()),
+			Params: make(map[string]string),
+		}
+
+		for {
+			skipSpace()
+
+			// remember the position in case the token is the next scheme
+			rest := s
+			key := readToken()
+			skipSpace()
+
+			if key == "" || !strings.HasPrefix(s, "=") {
+				s = rest
+				break
+			}
+
+			s = s[1:]
+
+			// token68, e.g. "Negotiate abc=="
+			if strings.HasPrefix(s, "=") || s == "" || s[0] == ',' {
+				s = strings.TrimLeft(s, "=")
+				skipSpace()
+				s = strings.TrimPrefix(s, ",")
+				continue
+			}
+
+			skipSpace()
+
+			var value string
+
+			if strings.HasPrefix(s, `"`) {
+				value = readQuoted()
+			} else {
+				value = readToken()
+			}
+
+			challenge.Params[strings.ToLower(key)] = value
+
+			skipSpace()
+
+			if !strings.HasPrefix(s, ",") {
+				break
+			}
+
+			s = s[1:]
+		}
+
+		challenges = append(challenges, challenge)
+	}
+}
+
+func findAuthChallenge(res *http.Response, scheme string) (challenge authChallenge, ok bool) {
+	for _, header := range res.Header.Values("WWW-Authenticate") {
+		for _, c := range parseAuthChallenges(header) {
+			if c.Scheme == scheme {
+				return c, true
+			}
+		}
+	}
+
+	return authChallenge{}, false
+}
+
+// quoteAuthParam returns s as a quoted-string (RFC 9110 section 5.6.4).
+func quoteAuthParam(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,157 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// BandwidthLimiter is a token bucket limiting the number of bytes per second.
+// It can be shared by multiple requests and clients.
+type BandwidthLimiter struct {
+	mutex  sync.Mutex
+	limit  int64
+	burst  int64
+	tokens float64
+	last   time.Time
+}
+
+// NewBandwidthLimiter creates a limiter with limit bytes per second. burst is
+// the bucket size and defaults to limit. limit <= 0 means no limit.
+func NewBandwidthLimiter(limit int64, burst int64) *BandwidthLimiter {
+	l := &BandwidthLimiter{}
+	l.SetLimit(limit, burst)
+	return l
+}
+
+// SetLimit changes the limit at runtime.
+func (l *BandwidthLimiter) SetLimit(limit int64, burst int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if burst <= 0 {
+		burst = limit
+	}
+
+	if l.last.IsZero() {
+		// start with a full bucket
+		l.tokens = float64(burst)
+	}
+
+	l.refill(time.Now())
+
+	l.limit = limit
+	l.burst = burst
+
+	if l.tokens > float64(burst) {
+		l.tokens = float64(burst)
+	}
+}
+
+func (l *BandwidthLimiter) Limit() (limit int64, burst int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.limit, l.burst
+}
+
+func (l *BandwidthLimiter) refill(now time.Time) {
+	if !l.last.IsZero() && l.limit > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.limit)
+
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+
+	l.last = now
+}
+
+// reserve takes n tokens and returns how long to wait until they are
+// available.
+func (l *BandwidthLimiter) reserve(n int) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.limit <= 0 {
+		return 0
+	}
+
+	l.refill(time.Now())
+
+	l.tokens -= float64(n)
+
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / float64(l.limit) * float64(time.Second))
+}
+
+// WaitN blocks until n bytes can be transferred.
+func (l *BandwidthLimiter) WaitN(ctx context.Context, n int) error {
+	return sleepContext(ctx, l.reserve(n))
+}
+
+func (l *BandwidthLimiter) chunkSize() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.limit <= 0 {
+		return 0
+	}
+
+	return int(l.burst)
+}
+
+type limitedReader struct {
+	reader   io.ReadCloser
+	ctx      context.Context
+	limiters []*BandwidthLimiter
+}
+
+func newLimitedReader(ctx context.Context, reader io.ReadCloser, limiters ...*BandwidthLimiter) io.ReadCloser {
+	r := &limitedReader{
+		reader: reader,
+		ctx:    ctx,
+	}
+
+	for _, l := range limiters {
+		if l != nil {
+			r.limiters = append(r.limiters, l)
+		}
+	}
+
+	if len(r.limiters) == 0 {
+		return reader
+	}
+
+	return r
+}
+
+func (r *limitedReader) Read(p []byte) (n int, err error) {
+	// read at most burst bytes at once so that a single read does not exceed
+	// the bucket
+	for _, l := range r.limiters {
+		if size := l.chunkSize(); size > 0 && len(p) > size {
+			p = p[:size]
+		}
+	}
+
+	n, err = r.reader.Read(p)
+
+	if n > 0 {
+		for _, l := range r.limiters {
+			if waitErr := l.WaitN(r.ctx, n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+
+	return n, err
+}
+
+func (r *limitedReader) Close() error {
+	return r.reader.Close()
+}
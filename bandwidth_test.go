@@ -0,0 +1,146 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("BandwidthLimiter", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+
+	content := bytes.Repeat([]byte("0123456789"), 5000)
+
+	BeforeEach(func() {
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+
+			if r.Method == "PUT" {
+				body, _ := io.ReadAll(r.Body)
+				Expect(body).To(Equal(content))
+				return
+			}
+
+			w.Write(content)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	download := func(c *HTTPClient, limiter *BandwidthLimiter) {
+		res, err := c.Request(&RequestData{
+			Method:          "GET",
+			Path:            "/",
+			DownloadLimiter: limiter,
+			ExpectedStatus:  []int{http.StatusOK},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal(content))
+	}
+
+	It("should allow a burst and then limit the rate", func() {
+		limiter := NewBandwidthLimiter(1000, 500)
+
+		start := time.Now()
+		Expect(limiter.WaitN(context.Background(), 500)).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond))
+
+		Expect(limiter.WaitN(context.Background(), 100)).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 90*time.Millisecond))
+	})
+
+	It("should stop waiting when context is canceled", func() {
+		limiter := NewBandwidthLimiter(10, 10)
+		limiter.WaitN(context.Background(), 10)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		Expect(limiter.WaitN(ctx, 10)).To(Equal(context.DeadlineExceeded))
+	})
+
+	It("should limit download per request", func() {
+		start := time.Now()
+		download(client, NewBandwidthLimiter(100000, 10000))
+		Expect(time.Since(start)).To(BeNumerically(">=", 350*time.Millisecond))
+	})
+
+	It("should limit upload per request", func() {
+		start := time.Now()
+
+		_, err := client.Request(&RequestData{
+			Method:         "PUT",
+			Path:           "/",
+			ReqReader:      bytes.NewReader(content),
+			UploadLimiter:  NewBandwidthLimiter(100000, 10000),
+			ExpectedStatus: []int{http.StatusOK},
+			RespConsume:    true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically(">=", 350*time.Millisecond))
+	})
+
+	It("should share limit between clients", func() {
+		limiter := NewBandwidthLimiter(200000, 10000)
+
+		other := New()
+		other.Client = client.Client
+		other.BaseURL = client.BaseURL
+
+		client.SetBandwidthLimiters(nil, limiter)
+		other.SetBandwidthLimiters(nil, limiter)
+
+		start := time.Now()
+
+		var wg sync.WaitGroup
+		for _, c := range []*HTTPClient{client, other} {
+			wg.Add(1)
+			go func(c *HTTPClient) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				download(c, nil)
+			}(c)
+		}
+		wg.Wait()
+
+		Expect(time.Since(start)).To(BeNumerically(">=", 400*time.Millisecond))
+	})
+
+	It("should change limit at runtime", func() {
+		limiter := NewBandwidthLimiter(1000, 1000)
+		client.SetBandwidthLimiters(nil, limiter)
+
+		limiter.SetLimit(0, 0)
+
+		start := time.Now()
+		download(client, nil)
+		Expect(time.Since(start)).To(BeNumerically("<", 200*time.Millisecond))
+
+		limit, burst := limiter.Limit()
+		Expect(limit).To(Equal(int64(0)))
+		Expect(burst).To(Equal(int64(0)))
+	})
+})
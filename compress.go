@@ -0,0 +1,151 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionNone = "identity"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+func newCompressWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+
+	return nil, fmt.Errorf("HTTPClient: invalid ReqCompression: %s", encoding)
+}
+
+func inMemoryLen(reader io.Reader) (size int64, ok bool) {
+	switch r := reader.(type) {
+	case *bytes.Reader:
+		return int64(r.Len()), true
+	case *strings.Reader:
+		return int64(r.Len()), true
+	case *bytes.Buffer:
+		return int64(r.Len()), true
+	}
+
+	return 0, false
+}
+
+func (c *HTTPClient) compressRequest(req *RequestData, body io.Reader, contentLength int64) (_ io.Reader, _ int64, encoding string, err error) {
+	encoding = req.ReqCompression
+	minSize := req.ReqCompressionMinSize
+
+	if encoding == "" {
+		encoding = c.reqCompression
+		minSize = c.reqCompressionMinSize
+	}
+
+	if body == nil || encoding == "" || encoding == CompressionNone {
+		return body, contentLength, "", nil
+	}
+
+	if req.Headers != nil && req.Headers.Get("Content-Encoding") != "" {
+		return body, contentLength, "", nil
+	}
+
+	size, inMemory := inMemoryLen(body)
+
+	if contentLength > 0 {
+		size = contentLength
+	} else if !inMemory {
+		// unknown size, the body is always compressed
+		size = -1
+	}
+
+	if size == 0 || (size > 0 && size < minSize) {
+		return body, contentLength, "", nil
+	}
+
+	if inMemory {
+		var buf bytes.Buffer
+
+		w, err := newCompressWriter(encoding, &buf)
+
+		if err != nil {
+			return nil, 0, "", err
+		}
+
+		if _, err = io.Copy(w, body); err != nil {
+			return nil, 0, "", err
+		}
+
+		if err = w.Close(); err != nil {
+			return nil, 0, "", err
+		}
+
+		return bytes.NewReader(buf.Bytes()), int64(buf.Len()), encoding, nil
+	}
+
+	pr, pw := io.Pipe()
+
+	w, err := newCompressWriter(encoding, pw)
+
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return &compressReader{
+		body: body,
+		w:    w,
+		pr:   pr,
+		pw:   pw,
+	}, 0, encoding, nil
+}
+
+// compressReader compresses body in a goroutine that is started on the first
+// Read, so nothing is left running if the request is not sent.
+type compressReader struct {
+	body  io.Reader
+	w     io.WriteCloser
+	pr    *io.PipeReader
+	pw    *io.PipeWriter
+	start sync.Once
+}
+
+func (r *compressReader) compress() {
+	_, err := io.Copy(r.w, r.body)
+
+	if err == nil {
+		err = r.w.Close()
+	}
+
+	r.closeBody()
+
+	r.pw.CloseWithError(err)
+}
+
+func (r *compressReader) closeBody() {
+	if closer, ok := r.body.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+func (r *compressReader) Read(p []byte) (n int, err error) {
+	r.start.Do(func() {
+		go r.compress()
+	})
+
+	return r.pr.Read(p)
+}
+
+func (r *compressReader) Close() error {
+	// the goroutine closes the body once it stops writing to the pipe
+	r.start.Do(r.closeBody)
+
+	return r.pr.Close()
+}
@@ -0,0 +1,229 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+func decompressBody(r *http.Request) []byte {
+	var reader io.Reader = r.Body
+
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip":
+		gr, err := gzip.NewReader(r.Body)
+		Expect(err).NotTo(HaveOccurred())
+		reader = gr
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body)
+		Expect(err).NotTo(HaveOccurred())
+		defer zr.Close()
+		reader = zr
+	}
+
+	data, err := io.ReadAll(reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	return data
+}
+
+var _ = Describe("Request compression", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var handler func(http.ResponseWriter, *http.Request)
+
+	BeforeEach(func() {
+		handler = nil
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			handler(w, r)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	It("should compress JSON request body with gzip", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(r.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(r.ContentLength).To(BeNumerically(">", 0))
+			Expect(r.Header.Get("Content-Length")).To(Equal(strconv.FormatInt(r.ContentLength, 10)))
+			Expect(r.TransferEncoding).To(BeEmpty())
+			Expect(string(decompressBody(r))).To(Equal(`{"key":"value"}`))
+		}
+
+		_, err := client.Request(&RequestData{
+			Method:         "POST",
+			Path:           "/",
+			ReqEncoding:    EncodingJSON,
+			ReqValue:       map[string]string{"key": "value"},
+			ReqCompression: CompressionGzip,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should compress request body with zstd", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Content-Encoding")).To(Equal("zstd"))
+			Expect(string(decompressBody(r))).To(Equal("body"))
+		}
+
+		_, err := client.Request(&RequestData{
+			Method:         "POST",
+			Path:           "/",
+			ReqReader:      strings.NewReader("body"),
+			ReqCompression: CompressionZstd,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should use client compression settings and threshold", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			body := decompressBody(r)
+
+			if len(body) < 100 {
+				Expect(r.Header.Get("Content-Encoding")).To(BeEmpty())
+			} else {
+				Expect(r.Header.Get("Content-Encoding")).To(Equal("gzip"))
+			}
+		}
+
+		client.SetReqCompression(CompressionGzip, 100)
+
+		_, err := client.Request(&RequestData{
+			Method:    "POST",
+			Path:      "/",
+			ReqReader: bytes.NewReader([]byte("small")),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.Request(&RequestData{
+			Method:    "POST",
+			Path:      "/",
+			ReqReader: bytes.NewReader(bytes.Repeat([]byte("large"), 100)),
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should not compress request body with CompressionNone", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Content-Encoding")).To(BeEmpty())
+			Expect(string(decompressBody(r))).To(Equal("body"))
+		}
+
+		client.SetReqCompression(CompressionGzip, 0)
+
+		_, err := client.Request(&RequestData{
+			Method:         "POST",
+			Path:           "/",
+			ReqReader:      strings.NewReader("body"),
+			ReqCompression: CompressionNone,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should compress streamed upload", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(r.Header.Get("Content-Length")).To(BeEmpty())
+			Expect(r.TransferEncoding).To(Equal([]string{"chunked"}))
+
+			_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			Expect(err).NotTo(HaveOccurred())
+
+			mr := multipart.NewReader(bytes.NewReader(decompressBody(r)), params["boundary"])
+			part, err := mr.NextPart()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(part.FileName()).To(Equal("file.txt"))
+			data, _ := io.ReadAll(part)
+			Expect(string(data)).To(Equal("content"))
+		}
+
+		req := &RequestData{
+			Method:         "POST",
+			Path:           "/",
+			ReqCompression: CompressionGzip,
+		}
+
+		err := req.UploadFile("file", "file.txt", &onlyReader{strings.NewReader("content")})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.Request(req)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should not read streamed body if request is not sent", func() {
+		client.SetRateLimit(0, 10*time.Millisecond)
+
+		body := &firstReadReader{r: strings.NewReader("body"), read: make(chan struct{})}
+
+		_, err := client.Request(&RequestData{
+			Method:         "POST",
+			Path:           "/",
+			ReqReader:      body,
+			ReqCompression: CompressionGzip,
+		})
+		Expect(err).To(Equal(RateLimitTimeoutError))
+
+		Consistently(body.read, 100*time.Millisecond).ShouldNot(BeClosed())
+	})
+
+	It("should fail with invalid ReqCompression", func() {
+		_, err := client.Request(&RequestData{
+			Method:         "POST",
+			Path:           "/",
+			ReqReader:      strings.NewReader("body"),
+			ReqCompression: "invalid",
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("HTTPClient: invalid ReqCompression: invalid"))
+	})
+})
+
+type onlyReader struct {
+	r io.Reader
+}
+
+func (r *onlyReader) Read(p []byte) (n int, err error) {
+	return r.r.Read(p)
+}
+
+// firstReadReader closes read on the first Read.
+type firstReadReader struct {
+	r    io.Reader
+	read chan struct{}
+	once sync.Once
+}
+
+func (r *firstReadReader) Read(p []byte) (n int, err error) {
+	r.once.Do(func() {
+		close(r.read)
+	})
+
+	return r.r.Read(p)
+}
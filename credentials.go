@@ -0,0 +1,431 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Credentials add authentication to a request.
+type Credentials interface {
+	Apply(req *http.Request) error
+}
+
+type BasicCredentials struct {
+	Username string
+	Password string
+}
+
+func (c BasicCredentials) Apply(req *http.Request) error {
+	req.SetBasicAuth(c.Username, c.Password)
+	return nil
+}
+
+type BearerCredentials struct {
+	Token string
+}
+
+func (c BearerCredentials) Apply(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	return nil
+}
+
+// HeaderAPIKey sends the key in a header, e.g. X-API-Key.
+type HeaderAPIKey struct {
+	Header string
+	Key    string
+}
+
+func (c HeaderAPIKey) Apply(req *http.Request) error {
+	req.Header.Set(c.Header, c.Key)
+	return nil
+}
+
+// QueryAPIKey sends the key as a query parameter.
+type QueryAPIKey struct {
+	Param string
+	Key   string
+}
+
+func (c QueryAPIKey) Apply(req *http.Request) error {
+	query := req.URL.Query()
+	query.Set(c.Param, c.Key)
+	req.URL.RawQuery = query.Encode()
+	return nil
+}
+
+// credentialFields returns the headers and query parameters set by
+// credentials.
+func credentialFields(credentials Credentials) (header http.Header, query url.Values, err error) {
+	r := &http.Request{
+		Header: make(http.Header),
+		URL:    &url.URL{},
+	}
+
+	if err = credentials.Apply(r); err != nil {
+		return nil, nil, err
+	}
+
+	return r.Header, r.URL.Query(), nil
+}
+
+type CredentialMode int
+
+const (
+	// CredentialsFailover uses the first credentials until they fail.
+	CredentialsFailover CredentialMode = iota
+	// CredentialsRoundRobin rotates the credentials for every request.
+	CredentialsRoundRobin
+)
+
+// DefaultCredentialCooldown is how long failed credentials are skipped.
+var DefaultCredentialCooldown = time.Minute
+
+// CredentialLoader returns the current credentials, see CredentialSet.Reload.
+type CredentialLoader func() ([]Credentials, error)
+
+// CredentialSet is an Authenticator that uses one of several credentials.
+// If a response has one of FailoverStatus the credentials are skipped for
+// Cooldown (or Retry-After) and the request is retried with the next ones.
+type CredentialSet struct {
+	Mode           CredentialMode
+	FailoverStatus []int         // defaults to 401, 403 and 429
+	Cooldown       time.Duration // defaults to DefaultCredentialCooldown
+	Now            func() time.Time
+
+	mutex       sync.Mutex
+	credentials []Credentials
+	failedUntil []time.Time
+	next        int
+}
+
+func NewCredentialSet(mode CredentialMode, credentials ...Credentials) *CredentialSet {
+	s := &CredentialSet{
+		Mode: mode,
+	}
+
+	s.SetCredentials(credentials)
+
+	return s
+}
+
+func (s *CredentialSet) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+
+	return time.Now()
+}
+
+// SetCredentials replaces the credentials. The failures are kept if the
+// credentials did not change.
+func (s *CredentialSet) SetCredentials(credentials []Credentials) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if reflect.DeepEqual(credentials, s.credentials) {
+		return
+	}
+
+	s.credentials = append([]Credentials(nil), credentials...)
+	s.failedUntil = make([]time.Time, len(credentials))
+	s.next = 0
+}
+
+func (s *CredentialSet) Credentials() []Credentials {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Credentials(nil), s.credentials...)
+}
+
+// Reload replaces the credentials with the ones returned by load. The
+// credentials are not changed if load fails.
+func (s *CredentialSet) Reload(load CredentialLoader) error {
+	credentials, err := load()
+
+	if err != nil {
+		return err
+	}
+
+	if len(credentials) == 0 {
+		return fmt.Errorf("HTTPClient: no credentials loaded")
+	}
+
+	s.SetCredentials(credentials)
+
+	return nil
+}
+
+// StartReload reloads the credentials every interval until ctx is done.
+// onError can be nil.
+func (s *CredentialSet) StartReload(ctx context.Context, load CredentialLoader, interval time.Duration, onError func(error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Reload(load); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// pick returns the index of the credentials to use. Credentials that failed
+// are skipped unless all of them failed, then the one that is available
+// first is used.
+func (s *CredentialSet) pick() (index int, ok bool) {
+	n := len(s.credentials)
+	now := s.now()
+
+	start := 0
+
+	if s.Mode == CredentialsRoundRobin {
+		start = s.next
+	}
+
+	index = -1
+
+	for i := 0; i < n; i++ {
+		j := (start + i) % n
+
+		if !now.Before(s.failedUntil[j]) {
+			index = j
+			break
+		}
+
+		if index < 0 || s.failedUntil[j].Before(s.failedUntil[index]) {
+			index = j
+		}
+	}
+
+	if index < 0 {
+		return 0, false
+	}
+
+	if s.Mode == CredentialsRoundRobin {
+		s.next = (index + 1) % n
+	}
+
+	return index, true
+}
+
+func (s *CredentialSet) Apply(req *http.Request) error {
+	return s.Authenticate(req)
+}
+
+func (s *CredentialSet) Authenticate(req *http.Request) error {
+	s.mutex.Lock()
+
+	index, ok := s.pick()
+
+	if !ok {
+		s.mutex.Unlock()
+		return fmt.Errorf("HTTPClient: no credentials")
+	}
+
+	credentials := s.credentials[index]
+	all := s.credentials
+
+	s.mutex.Unlock()
+
+	return applyCredentials(req, credentials, all)
+}
+
+// applyCredentials removes the fields of the other credentials (set by a
+// previous attempt) and applies credentials.
+func applyCredentials(req *http.Request, credentials Credentials, all []Credentials) error {
+	for _, c := range all {
+		header, query, err := credentialFields(c)
+
+		if err != nil {
+			return err
+		}
+
+		for name := range header {
+			req.Header.Del(name)
+		}
+
+		if len(query) > 0 {
+			q := req.URL.Query()
+
+			for name := range query {
+				q.Del(name)
+			}
+
+			req.URL.RawQuery = q.Encode()
+		}
+	}
+
+	return credentials.Apply(req)
+}
+
+// usedCredentials returns the index of the credentials applied to req.
+func (s *CredentialSet) usedCredentials(req *http.Request) (index int, ok bool) {
+	query := req.URL.Query()
+
+	for i, c := range s.credentials {
+		header, q, err := credentialFields(c)
+
+		if err != nil {
+			continue
+		}
+
+		matches := true
+
+		for name := range header {
+			if req.Header.Get(name) != header.Get(name) {
+				matches = false
+			}
+		}
+
+		for name := range q {
+			if query.Get(name) != q.Get(name) {
+				matches = false
+			}
+		}
+
+		if matches {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (s *CredentialSet) challengeStatus(status int) bool {
+	failoverStatus := s.FailoverStatus
+
+	if failoverStatus == nil {
+		failoverStatus = []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests}
+	}
+
+	for _, st := range failoverStatus {
+		if st == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Challenge marks the credentials as failed and retries the request with the
+// next credentials that were not tried yet.
+func (s *CredentialSet) Challenge(req *http.Request, res *http.Response, attempt int) (retry bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	index, ok := s.usedCredentials(req)
+
+	if !ok {
+		return false, nil
+	}
+
+	cooldown := s.Cooldown
+
+	if cooldown == 0 {
+		cooldown = DefaultCredentialCooldown
+	}
+
+	if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), s.now()); ok && retryAfter > cooldown {
+		cooldown = retryAfter
+	}
+
+	s.failedUntil[index] = s.now().Add(cooldown)
+
+	if attempt >= len(s.credentials) {
+		return false, nil
+	}
+
+	// the credentials tried by previous attempts are failed as well
+	now := s.now()
+
+	for i := range s.credentials {
+		if !now.Before(s.failedUntil[i]) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// parseRetryAfter parses the Retry-After header in seconds or as an HTTP
+// date.
+func parseRetryAfter(value string, now time.Time) (d time.Duration, ok bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now), true
+	}
+
+	return 0, false
+}
+
+type credentialsFileEntry struct {
+	Type     string `json:"type"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Key      string `json:"key"`
+}
+
+// CredentialsFile loads credentials from a JSON file, e.g.
+//
+//	[
+//	  {"type": "basic", "username": "user", "password": "pass"},
+//	  {"type": "bearer", "token": "token"},
+//	  {"type": "header", "name": "X-API-Key", "key": "key"},
+//	  {"type": "query", "name": "api_key", "key": "key"}
+//	]
+func CredentialsFile(path string) CredentialLoader {
+	return func() (credentials []Credentials, err error) {
+		data, err := os.ReadFile(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var entries []credentialsFileEntry
+
+		if err = json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			switch entry.Type {
+			case "basic":
+				credentials = append(credentials, BasicCredentials{Username: entry.Username, Password: entry.Password})
+			case "bearer":
+				credentials = append(credentials, BearerCredentials{Token: entry.Token})
+			case "header":
+				credentials = append(credentials, HeaderAPIKey{Header: entry.Name, Key: entry.Key})
+			case "query":
+				credentials = append(credentials, QueryAPIKey{Param: entry.Name, Key: entry.Key})
+			default:
+				return nil, fmt.Errorf("HTTPClient: invalid credentials type: %s", entry.Type)
+			}
+		}
+
+		return credentials, nil
+	}
+}
@@ -0,0 +1,190 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("Credentials", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var requests []*http.Request
+	var handler func(w http.ResponseWriter, r *http.Request)
+
+	BeforeEach(func() {
+		requests = nil
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			handler(w, r)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	get := func() error {
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			Params:         url.Values{"a": {"1"}},
+			ExpectedStatus: []int{http.StatusOK},
+			RespConsume:    true,
+		})
+		return err
+	}
+
+	It("should apply credentials", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {}
+
+		client.SetCredentials(BasicCredentials{Username: "user", Password: "pass"})
+		Expect(get()).To(Succeed())
+		username, password, _ := requests[0].BasicAuth()
+		Expect(username).To(Equal("user"))
+		Expect(password).To(Equal("pass"))
+
+		client.SetCredentials(BearerCredentials{Token: "token"})
+		Expect(get()).To(Succeed())
+		Expect(requests[1].Header.Get("Authorization")).To(Equal("Bearer token"))
+
+		client.SetCredentials(HeaderAPIKey{Header: "X-API-Key", Key: "key"})
+		Expect(get()).To(Succeed())
+		Expect(requests[2].Header.Get("X-API-Key")).To(Equal("key"))
+
+		client.SetCredentials(QueryAPIKey{Param: "api_key", Key: "key"})
+		Expect(get()).To(Succeed())
+		Expect(requests[3].URL.RawQuery).To(Equal("a=1&api_key=key"))
+	})
+
+	It("should fail over to the next credentials", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-API-Key") != "b" {
+				w.WriteHeader(http.StatusForbidden)
+			}
+		}
+
+		now := time.Now()
+
+		set := NewCredentialSet(CredentialsFailover,
+			QueryAPIKey{Param: "api_key", Key: "a"},
+			HeaderAPIKey{Header: "X-API-Key", Key: "b"},
+		)
+		set.Now = func() time.Time {
+			return now
+		}
+
+		client.SetCredentials(set)
+
+		Expect(get()).To(Succeed())
+		Expect(requests).To(HaveLen(2))
+		Expect(requests[0].URL.RawQuery).To(Equal("a=1&api_key=a"))
+		Expect(requests[1].URL.RawQuery).To(Equal("a=1"))
+		Expect(requests[1].Header.Get("X-API-Key")).To(Equal("b"))
+
+		// a is skipped during the cooldown
+		Expect(get()).To(Succeed())
+		Expect(requests).To(HaveLen(3))
+		Expect(requests[2].Header.Get("X-API-Key")).To(Equal("b"))
+
+		now = now.Add(DefaultCredentialCooldown)
+
+		Expect(get()).To(Succeed())
+		Expect(requests).To(HaveLen(5))
+		Expect(requests[3].URL.Query().Get("api_key")).To(Equal("a"))
+	})
+
+	It("should return the error if all credentials fail", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+
+		client.SetCredentials(NewCredentialSet(CredentialsFailover,
+			BearerCredentials{Token: "a"},
+			BearerCredentials{Token: "b"},
+		))
+
+		Expect(IsInvalidStatusCode(get(), http.StatusTooManyRequests)).To(BeTrue())
+		Expect(requests).To(HaveLen(2))
+
+		// all failed, the one that is available first is used
+		Expect(IsInvalidStatusCode(get(), http.StatusTooManyRequests)).To(BeTrue())
+		Expect(requests).To(HaveLen(3))
+		Expect(requests[2].Header.Get("Authorization")).To(Equal("Bearer a"))
+	})
+
+	It("should rotate credentials with round robin", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {}
+
+		client.SetCredentials(NewCredentialSet(CredentialsRoundRobin,
+			BearerCredentials{Token: "a"},
+			BearerCredentials{Token: "b"},
+			BearerCredentials{Token: "c"},
+		))
+
+		for i := 0; i < 4; i++ {
+			Expect(get()).To(Succeed())
+		}
+
+		tokens := []string{}
+
+		for _, r := range requests {
+			tokens = append(tokens, r.Header.Get("Authorization"))
+		}
+
+		Expect(tokens).To(Equal([]string{"Bearer a", "Bearer b", "Bearer c", "Bearer a"}))
+	})
+
+	It("should reload credentials from a file", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {}
+
+		path := filepath.Join(GinkgoT().TempDir(), "credentials.json")
+
+		Expect(os.WriteFile(path, []byte(`[{"type": "bearer", "token": "a"}, {"type": "basic", "username": "u", "password": "p"}]`), 0600)).To(Succeed())
+
+		set := NewCredentialSet(CredentialsFailover)
+		Expect(set.Reload(CredentialsFile(path))).To(Succeed())
+		Expect(set.Credentials()).To(Equal([]Credentials{
+			BearerCredentials{Token: "a"},
+			BasicCredentials{Username: "u", Password: "p"},
+		}))
+
+		client.SetCredentials(set)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		set.StartReload(ctx, CredentialsFile(path), 10*time.Millisecond, nil)
+
+		Expect(os.WriteFile(path, []byte(`[{"type": "header", "name": "X-API-Key", "key": "b"}, {"type": "query", "name": "k", "key": "c"}]`), 0600)).To(Succeed())
+
+		Eventually(set.Credentials).Should(Equal([]Credentials{
+			HeaderAPIKey{Header: "X-API-Key", Key: "b"},
+			QueryAPIKey{Param: "k", Key: "c"},
+		}))
+
+		Expect(get()).To(Succeed())
+		Expect(requests[0].Header.Get("X-API-Key")).To(Equal("b"))
+
+		Expect(os.WriteFile(path, []byte(`[{"type": "invalid"}]`), 0600)).To(Succeed())
+		Expect(set.Reload(CredentialsFile(path))).To(MatchError("HTTPClient: invalid credentials type: invalid"))
+		Expect(set.Credentials()).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,184 @@
+package httpclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const AcceptEncoding = "gzip, deflate, br, zstd"
+
+func isSupportedContentEncoding(encoding string) bool {
+	switch encoding {
+	case "gzip", "x-gzip", "deflate", "br", "zstd", "identity":
+		return true
+	}
+
+	return false
+}
+
+func parseContentEncoding(header string) (encodings []string, ok bool) {
+	for _, encoding := range strings.Split(header, ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+
+		if encoding == "" {
+			continue
+		}
+
+		if !isSupportedContentEncoding(encoding) {
+			return nil, false
+		}
+
+		if encoding != "identity" {
+			encodings = append(encodings, encoding)
+		}
+	}
+
+	return encodings, true
+}
+
+func (c *HTTPClient) shouldDecompress(req *RequestData, httpReq *http.Request) bool {
+	if c.disableDecompression || req.DisableDecompression {
+		return false
+	}
+
+	if httpReq.Method == "HEAD" {
+		return false
+	}
+
+	// Respect encodings and ranges explicitly requested by the caller. Partial
+	// content of a compressed representation cannot be decoded on its own.
+	if httpReq.Header.Get("Accept-Encoding") != "" || httpReq.Header.Get("Range") != "" {
+		return false
+	}
+
+	return true
+}
+
+func decompressResponse(response *http.Response) {
+	header := response.Header.Get("Content-Encoding")
+
+	if header == "" {
+		return
+	}
+
+	encodings, ok := parseContentEncoding(header)
+
+	if !ok {
+		return
+	}
+
+	if len(encodings) > 0 {
+		response.Body = &decompressReader{
+			body:      response.Body,
+			encodings: encodings,
+		}
+	}
+
+	response.Header.Del("Content-Encoding")
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	response.Uncompressed = true
+}
+
+// decompressReader decodes the body lazily so that empty bodies (e.g. 204 or
+// 304 responses with a Content-Encoding header) do not fail.
+type decompressReader struct {
+	body      io.ReadCloser
+	encodings []string
+	reader    io.Reader
+	closers   []func()
+	err       error
+}
+
+func (r *decompressReader) init() (err error) {
+	var reader io.Reader = r.body
+
+	// encodings are listed in the order they were applied
+	for i := len(r.encodings) - 1; i >= 0; i-- {
+		switch r.encodings[i] {
+		case "gzip", "x-gzip":
+			gr, err := gzip.NewReader(reader)
+
+			if err != nil {
+				return err
+			}
+
+			r.closers = append(r.closers, func() { gr.Close() })
+			reader = gr
+
+		case "deflate":
+			fr := newDeflateReader(reader)
+
+			r.closers = append(r.closers, func() { fr.Close() })
+			reader = fr
+
+		case "br":
+			reader = brotli.NewReader(reader)
+
+		case "zstd":
+			zr, err := zstd.NewReader(reader, zstd.WithDecoderConcurrency(1))
+
+			if err != nil {
+				return err
+			}
+
+			r.closers = append(r.closers, zr.Close)
+			reader = zr
+
+		default:
+			return fmt.Errorf("HTTPClient: unsupported Content-Encoding: %s", r.encodings[i])
+		}
+	}
+
+	r.reader = reader
+
+	return nil
+}
+
+func (r *decompressReader) Read(p []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	if r.reader == nil {
+		if err = r.init(); err != nil {
+			r.err = err
+			return 0, err
+		}
+	}
+
+	return r.reader.Read(p)
+}
+
+func (r *decompressReader) Close() error {
+	for _, closer := range r.closers {
+		closer()
+	}
+
+	return r.body.Close()
+}
+
+// newDeflateReader handles both zlib wrapped (RFC 1950, as specified for HTTP)
+// and raw deflate (RFC 1951, as sent by some servers) streams.
+func newDeflateReader(r io.Reader) io.ReadCloser {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2)
+
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(br); err == nil {
+			return zr
+		}
+	}
+
+	return flate.NewReader(br)
+}
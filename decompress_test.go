@@ -0,0 +1,212 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+func compressBytes(encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, _ = zstd.NewWriter(&buf)
+	}
+
+	w.Write(data)
+	w.Close()
+
+	return buf.Bytes()
+}
+
+var _ = Describe("Decompression", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var handler func(http.ResponseWriter, *http.Request)
+
+	BeforeEach(func() {
+		handler = nil
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			handler(w, r)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.BaseURL = u
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	serveCompressed := func(encoding string, data []byte) {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Accept-Encoding")).To(Equal(AcceptEncoding))
+
+			headerEncoding := encoding
+			if encoding == "raw-deflate" {
+				headerEncoding = "deflate"
+			}
+
+			w.Header().Set("Content-Encoding", headerEncoding)
+			w.Write(compressBytes(encoding, data))
+		}
+	}
+
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate", "br", "zstd"} {
+		encoding := encoding
+
+		It(fmt.Sprintf("should decode %s JSON response", encoding), func() {
+			serveCompressed(encoding, []byte(`{"key":"value"}`))
+
+			var data map[string]string
+
+			_, err := client.Request(&RequestData{
+				Method:       "GET",
+				Path:         "/",
+				RespEncoding: EncodingJSON,
+				RespValue:    &data,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal(map[string]string{"key": "value"}))
+		})
+	}
+
+	It("should decode byte slice response and strip headers", func() {
+		serveCompressed("gzip", []byte("hello world"))
+
+		var data []byte
+
+		res, err := client.Request(&RequestData{
+			Method:    "GET",
+			Path:      "/",
+			RespValue: &data,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("hello world"))
+		Expect(res.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(res.Header.Get("Content-Length")).To(BeEmpty())
+		Expect(res.ContentLength).To(Equal(int64(-1)))
+		Expect(res.Uncompressed).To(BeTrue())
+	})
+
+	It("should decode invalid status error content", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(compressBytes("gzip", []byte("bad request")))
+		}
+
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			ExpectedStatus: []int{http.StatusOK},
+		})
+		ise, ok := IsInvalidStatusError(err)
+		Expect(ok).To(BeTrue())
+		Expect(ise.Content).To(Equal("bad request"))
+	})
+
+	It("should not decode response with DisableDecompression", func() {
+		compressed := compressBytes("gzip", []byte("hello world"))
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Accept-Encoding")).To(BeEmpty())
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(compressed)
+		}
+
+		var data []byte
+
+		res, err := client.Request(&RequestData{
+			Method:               "GET",
+			Path:                 "/",
+			RespValue:            &data,
+			DisableDecompression: true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(compressed))
+		Expect(res.Header.Get("Content-Encoding")).To(Equal("gzip"))
+	})
+
+	It("should not decode response if client decompression is disabled", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Accept-Encoding")).To(BeEmpty())
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(compressBytes("gzip", []byte("hello world")))
+		}
+
+		client.DisableDecompression()
+
+		res, err := client.Request(&RequestData{
+			Method:      "GET",
+			Path:        "/",
+			RespConsume: true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Header.Get("Content-Encoding")).To(Equal("gzip"))
+	})
+
+	It("should not decode response if Accept-Encoding is set by the caller", func() {
+		compressed := compressBytes("gzip", []byte("hello world"))
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Accept-Encoding")).To(Equal("gzip"))
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(compressed)
+		}
+
+		var data []byte
+
+		_, err := client.Request(&RequestData{
+			Method:    "GET",
+			Path:      "/",
+			Headers:   http.Header{"Accept-Encoding": {"gzip"}},
+			RespValue: &data,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(compressed))
+	})
+
+	It("should handle empty compressed responses", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		res, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			ExpectedStatus: []int{http.StatusNoContent},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Body.Close()).To(Succeed())
+	})
+})
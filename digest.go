@@ -0,0 +1,311 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	DigestSHA256 = "sha-256"
+	DigestSHA512 = "sha-512"
+	DigestMD5    = "md5"
+)
+
+var digestAlgorithms = map[string]func() hash.Hash{
+	DigestSHA256: sha256.New,
+	DigestSHA512: sha512.New,
+	DigestMD5:    md5.New,
+}
+
+// parseDigestFields parses Content-Digest and Repr-Digest (RFC 9530,
+// sha-256=:base64:) as well as legacy Digest (RFC 3230, SHA-256=base64) header
+// values. Keys are lowercased.
+func parseDigestFields(header string) map[string][]byte {
+	digests := make(map[string][]byte)
+
+	for _, field := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+
+		if !ok {
+			continue
+		}
+
+		// structured field parameters are not used
+		if i := strings.Index(value, ";"); i >= 0 {
+			value = value[:i]
+		}
+
+		value = strings.TrimSpace(value)
+
+		if len(value) >= 2 && strings.HasPrefix(value, ":") && strings.HasSuffix(value, ":") {
+			value = value[1 : len(value)-1]
+		}
+
+		sum, err := base64.StdEncoding.DecodeString(value)
+
+		if err != nil {
+			continue
+		}
+
+		digests[strings.ToLower(strings.TrimSpace(key))] = sum
+	}
+
+	return digests
+}
+
+// parseETagDigest returns the ETag as a digest if it is a hex encoded hash of
+// the expected size.
+func parseETagDigest(etag string, size int) (sum []byte, ok bool) {
+	if strings.HasPrefix(etag, "W/") {
+		return nil, false
+	}
+
+	etag = strings.Trim(etag, `"`)
+
+	if len(etag) != size*2 {
+		return nil, false
+	}
+
+	sum, err := hex.DecodeString(etag)
+
+	if err != nil {
+		return nil, false
+	}
+
+	return sum, true
+}
+
+// digestHeader returns the header used for the algorithm. MD5 uses the legacy
+// Content-MD5 header.
+func digestHeader(algorithm string) string {
+	if algorithm == DigestMD5 {
+		return "Content-MD5"
+	}
+
+	return "Content-Digest"
+}
+
+func formatDigest(algorithm string, sum []byte) string {
+	if algorithm == DigestMD5 {
+		return base64.StdEncoding.EncodeToString(sum)
+	}
+
+	return algorithm + "=:" + base64.StdEncoding.EncodeToString(sum) + ":"
+}
+
+// digestRequest sets the Content-Digest (or Content-MD5) of the request body.
+// Bodies that can be read again are hashed before the request is sent,
+// other bodies are hashed while they are sent and the digest is sent as a
+// trailer.
+func digestRequest(algorithm string, r *http.Request) (err error) {
+	newHash, ok := digestAlgorithms[algorithm]
+
+	if !ok {
+		return fmt.Errorf("HTTPClient: invalid ReqDigest: %s", algorithm)
+	}
+
+	header := digestHeader(algorithm)
+
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+
+		if err != nil {
+			return err
+		}
+
+		defer body.Close()
+
+		h := newHash()
+
+		if _, err = io.Copy(h, body); err != nil {
+			return err
+		}
+
+		r.Header.Set(header, formatDigest(algorithm, h.Sum(nil)))
+
+		return nil
+	}
+
+	// trailers are only sent with chunked encoding
+	r.ContentLength = -1
+	r.Trailer = http.Header{header: nil}
+	r.Body = &digestingReader{
+		reader: r.Body,
+		hash:   newHash(),
+		onEOF: func(sum []byte) {
+			r.Trailer.Set(header, formatDigest(algorithm, sum))
+		},
+	}
+
+	return nil
+}
+
+type digestingReader struct {
+	reader io.ReadCloser
+	hash   hash.Hash
+	onEOF  func(sum []byte)
+}
+
+func (r *digestingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+
+	r.hash.Write(p[:n])
+
+	if err == io.EOF && r.onEOF != nil {
+		r.onEOF(r.hash.Sum(nil))
+		r.onEOF = nil
+	}
+
+	return n, err
+}
+
+func (r *digestingReader) Close() error {
+	return r.reader.Close()
+}
+
+type expectedDigest struct {
+	header    string
+	algorithm string
+	sum       []byte
+}
+
+// responseDigests returns the supported digests of the response content from
+// Content-Digest, Repr-Digest and Content-MD5 headers.
+func responseDigests(res *http.Response, header http.Header) (digests []expectedDigest) {
+	headers := []string{"Content-Digest"}
+
+	// representation digest covers the full representation, not the range
+	if res.StatusCode != http.StatusPartialContent {
+		headers = append(headers, "Repr-Digest")
+	}
+
+	for _, name := range headers {
+		for algorithm, sum := range parseDigestFields(header.Get(name)) {
+			if algorithm == DigestSHA256 || algorithm == DigestSHA512 {
+				digests = append(digests, expectedDigest{name, algorithm, sum})
+			}
+		}
+	}
+
+	if value := header.Get("Content-MD5"); value != "" {
+		if sum, err := base64.StdEncoding.DecodeString(value); err == nil {
+			digests = append(digests, expectedDigest{"Content-MD5", DigestMD5, sum})
+		}
+	}
+
+	return digests
+}
+
+// verifyingReader verifies the digests from response headers and trailers once
+// the body is read. It returns IntegrityError instead of io.EOF on mismatch.
+type verifyingReader struct {
+	reader  io.ReadCloser
+	res     *http.Response
+	hashes  map[string]hash.Hash
+	writer  io.Writer
+	checked bool
+}
+
+func newVerifyingReader(res *http.Response) io.ReadCloser {
+	// the digests do not match the content decompressed by the transport
+	if res.Uncompressed || res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified {
+		return res.Body
+	}
+
+	if res.Request != nil && res.Request.Method == "HEAD" {
+		return res.Body
+	}
+
+	algorithms := map[string]bool{}
+
+	for _, d := range responseDigests(res, res.Header) {
+		algorithms[d.algorithm] = true
+	}
+
+	for _, name := range []string{"Content-Digest", "Repr-Digest", "Content-MD5"} {
+		if _, ok := res.Trailer[name]; ok {
+			// the algorithm is not known until the trailer is received
+			for algorithm := range digestAlgorithms {
+				algorithms[algorithm] = true
+			}
+		}
+	}
+
+	if len(algorithms) == 0 {
+		return res.Body
+	}
+
+	r := &verifyingReader{
+		reader: res.Body,
+		res:    res,
+		hashes: make(map[string]hash.Hash),
+	}
+
+	writers := []io.Writer{}
+
+	for algorithm := range algorithms {
+		h := digestAlgorithms[algorithm]()
+		r.hashes[algorithm] = h
+		writers = append(writers, h)
+	}
+
+	r.writer = io.MultiWriter(writers...)
+
+	return r
+}
+
+func (r *verifyingReader) verify() error {
+	digests := responseDigests(r.res, r.res.Header)
+
+	if r.res.Trailer != nil {
+		digests = append(digests, responseDigests(r.res, r.res.Trailer)...)
+	}
+
+	for _, d := range digests {
+		h, ok := r.hashes[d.algorithm]
+
+		if !ok {
+			continue
+		}
+
+		if sum := h.Sum(nil); !bytes.Equal(sum, d.sum) {
+			return IntegrityError{
+				Header:    d.header,
+				Algorithm: d.algorithm,
+				Expected:  d.sum,
+				Actual:    sum,
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *verifyingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+
+	r.writer.Write(p[:n])
+
+	if err == io.EOF && !r.checked {
+		r.checked = true
+
+		if verifyErr := r.verify(); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+
+	return n, err
+}
+
+func (r *verifyingReader) Close() error {
+	return r.reader.Close()
+}
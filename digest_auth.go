@@ -0,0 +1,261 @@
+package httpclient
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var digestAuthAlgorithms = map[string]func() hash.Hash{
+	"MD5":          md5.New,
+	"MD5-SESS":     md5.New,
+	"SHA-256":      sha256.New,
+	"SHA-256-SESS": sha256.New,
+}
+
+// preferred algorithms first
+var digestAuthAlgorithmOrder = []string{"SHA-256", "SHA-256-SESS", "MD5", "MD5-SESS"}
+
+type digestAuthChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	name      string // algorithm as sent by the server
+	qop       string
+	userhash  bool
+	stale     bool
+}
+
+// DigestAuthenticator implements HTTP Digest authentication (RFC 7616). The
+// challenge is reused for later requests so that only the first request gets
+// a 401 response.
+type DigestAuthenticator struct {
+	Username string
+	Password string
+	CNonce   func() string // defaults to a random cnonce
+
+	mutex     sync.Mutex
+	challenge *digestAuthChallenge
+	nc        uint32
+}
+
+func NewDigestAuthenticator(username string, password string) *DigestAuthenticator {
+	return &DigestAuthenticator{
+		Username: username,
+		Password: password,
+	}
+}
+
+func parseDigestAuthChallenge(c authChallenge) (challenge *digestAuthChallenge, ok bool) {
+	name := c.Params["algorithm"]
+
+	if name == "" {
+		name = "MD5"
+	}
+
+	algorithm := strings.ToUpper(name)
+
+	if _, ok := digestAuthAlgorithms[algorithm]; !ok {
+		return nil, false
+	}
+
+	challenge = &digestAuthChallenge{
+		realm:     c.Params["realm"],
+		nonce:     c.Params["nonce"],
+		opaque:    c.Params["opaque"],
+		algorithm: algorithm,
+		name:      name,
+		userhash:  strings.EqualFold(c.Params["userhash"], "true"),
+		stale:     strings.EqualFold(c.Params["stale"], "true"),
+	}
+
+	if qop, ok := c.Params["qop"]; ok {
+		options := map[string]bool{}
+
+		for _, option := range strings.Split(qop, ",") {
+			options[strings.TrimSpace(option)] = true
+		}
+
+		// auth-int is only used if the server does not accept auth
+		if options["auth"] {
+			challenge.qop = "auth"
+		} else if options["auth-int"] {
+			challenge.qop = "auth-int"
+		} else {
+			return nil, false
+		}
+	}
+
+	return challenge, true
+}
+
+func (a *DigestAuthenticator) Challenge(req *http.Request, res *http.Response, attempt int) (retry bool, err error) {
+	var best *digestAuthChallenge
+	bestIndex := len(digestAuthAlgorithmOrder)
+
+	for _, header := range res.Header.Values("WWW-Authenticate") {
+		for _, c := range parseAuthChallenges(header) {
+			if c.Scheme != "digest" {
+				continue
+			}
+
+			challenge, ok := parseDigestAuthChallenge(c)
+
+			if !ok {
+				continue
+			}
+
+			for i, algorithm := range digestAuthAlgorithmOrder {
+				if algorithm == challenge.algorithm && i < bestIndex {
+					best = challenge
+					bestIndex = i
+				}
+			}
+		}
+	}
+
+	if best == nil {
+		return false, nil
+	}
+
+	// the credentials were rejected unless only the nonce expired
+	if attempt > 1 && !best.stale {
+		return false, nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.challenge = best
+	a.nc = 0
+
+	return true, nil
+}
+
+func (a *DigestAuthenticator) Authenticate(req *http.Request) (err error) {
+	a.mutex.Lock()
+
+	challenge := a.challenge
+
+	if challenge == nil {
+		a.mutex.Unlock()
+		return nil
+	}
+
+	a.nc++
+	nc := fmt.Sprintf("%08x", a.nc)
+
+	a.mutex.Unlock()
+
+	newHash := digestAuthAlgorithms[challenge.algorithm]
+
+	h := func(s string) string {
+		hash := newHash()
+		hash.Write([]byte(s))
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+
+	cnonce := ""
+
+	if a.CNonce != nil {
+		cnonce = a.CNonce()
+	} else {
+		b := make([]byte, 16)
+
+		if _, err = rand.Read(b); err != nil {
+			return err
+		}
+
+		cnonce = base64.RawStdEncoding.EncodeToString(b)
+	}
+
+	uri := req.URL.RequestURI()
+
+	ha1 := h(a.Username + ":" + challenge.realm + ":" + a.Password)
+
+	if strings.HasSuffix(challenge.algorithm, "-SESS") {
+		ha1 = h(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+
+	a2 := req.Method + ":" + uri
+
+	if challenge.qop == "auth-int" {
+		bodyHash := newHash()
+
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return fmt.Errorf("HTTPClient: Digest auth-int request body cannot be hashed")
+			}
+
+			body, err := req.GetBody()
+
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(bodyHash, body)
+			body.Close()
+
+			if err != nil {
+				return err
+			}
+		}
+
+		a2 += ":" + hex.EncodeToString(bodyHash.Sum(nil))
+	}
+
+	ha2 := h(a2)
+
+	var response string
+
+	if challenge.qop != "" {
+		response = h(ha1 + ":" + challenge.nonce + ":" + nc + ":" + cnonce + ":" + challenge.qop + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + challenge.nonce + ":" + ha2)
+	}
+
+	username := a.Username
+
+	if challenge.userhash {
+		username = h(a.Username + ":" + challenge.realm)
+	}
+
+	params := []string{
+		"username=" + quoteAuthParam(username),
+		"realm=" + quoteAuthParam(challenge.realm),
+		"uri=" + quoteAuthParam(uri),
+		"algorithm=" + challenge.name,
+		"nonce=" + quoteAuthParam(challenge.nonce),
+	}
+
+	if challenge.qop != "" {
+		params = append(params,
+			"nc="+nc,
+			"cnonce="+quoteAuthParam(cnonce),
+			"qop="+challenge.qop,
+		)
+	}
+
+	params = append(params, "response="+quoteAuthParam(response))
+
+	if challenge.opaque != "" {
+		params = append(params, "opaque="+quoteAuthParam(challenge.opaque))
+	}
+
+	if challenge.userhash {
+		params = append(params, "userhash=true")
+	}
+
+	req.Header.Set("Authorization", "Digest "+strings.Join(params, ", "))
+
+	return nil
+}
@@ -0,0 +1,277 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var digestAuthParamRegexp = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|([^,\s]*))`)
+
+func parseDigestAuthorization(header string) map[string]string {
+	params := map[string]string{}
+
+	for _, m := range digestAuthParamRegexp.FindAllStringSubmatch(strings.TrimPrefix(header, "Digest "), -1) {
+		params[m[1]] = m[2] + m[3]
+	}
+
+	return params
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+var _ = Describe("DigestAuthenticator", func() {
+	// RFC 7616 section 3.9.1
+	const (
+		realm  = "http-auth@example.org"
+		nonce  = "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"
+		opaque = "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"
+		cnonce = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	)
+
+	var ts *httptest.Server
+	var client *HTTPClient
+	var auth *DigestAuthenticator
+	var handler func(w http.ResponseWriter, r *http.Request)
+	var authorizations []map[string]string
+	var authorizationHeaders []string
+
+	BeforeEach(func() {
+		authorizations = nil
+		authorizationHeaders = nil
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+
+			authorizationHeaders = append(authorizationHeaders, r.Header.Get("Authorization"))
+
+			if header := r.Header.Get("Authorization"); header != "" {
+				authorizations = append(authorizations, parseDigestAuthorization(header))
+			} else {
+				authorizations = append(authorizations, nil)
+			}
+
+			handler(w, r)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		auth = NewDigestAuthenticator("Mufasa", "Circle of Life")
+		auth.CNonce = func() string {
+			return cnonce
+		}
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+		client.SetAuthenticator(auth)
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	rfcHandler := func(challenges []string, expectedResponse string) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			params := authorizations[len(authorizations)-1]
+
+			if params == nil || params["response"] != expectedResponse {
+				for _, c := range challenges {
+					w.Header().Add("WWW-Authenticate", c)
+				}
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			fmt.Fprint(w, "ok")
+		}
+	}
+
+	It("should authenticate with RFC 7616 SHA-256 example", func() {
+		handler = rfcHandler([]string{
+			`Digest realm="` + realm + `", qop="auth, auth-int", algorithm=SHA-256, nonce="` + nonce + `", opaque="` + opaque + `"`,
+			`Digest realm="` + realm + `", qop="auth, auth-int", algorithm=MD5, nonce="` + nonce + `", opaque="` + opaque + `"`,
+		}, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1")
+
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/dir/index.html",
+			ExpectedStatus: []int{http.StatusOK},
+			RespConsume:    true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(authorizations).To(HaveLen(2))
+		Expect(authorizations[1]).To(Equal(map[string]string{
+			"username":  "Mufasa",
+			"realm":     realm,
+			"uri":       "/dir/index.html",
+			"algorithm": "SHA-256",
+			"nonce":     nonce,
+			"nc":        "00000001",
+			"cnonce":    cnonce,
+			"qop":       "auth",
+			"response":  "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+			"opaque":    opaque,
+		}))
+	})
+
+	It("should authenticate with RFC 7616 MD5 example and reuse the challenge", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			params := authorizations[len(authorizations)-1]
+
+			if params == nil {
+				w.Header().Set("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth", algorithm=MD5, nonce="`+nonce+`", opaque="`+opaque+`"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if params["nc"] == "00000001" {
+				Expect(params["response"]).To(Equal("8ca523f5e9506fed4657c9700eebdbec"))
+			}
+
+			fmt.Fprint(w, "ok")
+		}
+
+		for i := 0; i < 3; i++ {
+			_, err := client.Request(&RequestData{
+				Method:         "GET",
+				Path:           "/dir/index.html",
+				ExpectedStatus: []int{http.StatusOK},
+				RespConsume:    true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(authorizations).To(HaveLen(4))
+		Expect(authorizations[1]["nc"]).To(Equal("00000001"))
+		Expect(authorizations[2]["nc"]).To(Equal("00000002"))
+		Expect(authorizations[3]["nc"]).To(Equal("00000003"))
+	})
+
+	It("should support MD5-sess with auth-int", func() {
+		bodies := []string{}
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			params := authorizations[len(authorizations)-1]
+
+			if params == nil {
+				w.Header().Set("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth-int", algorithm=MD5-sess, nonce="`+nonce+`"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+
+			ha1 := md5Hex(md5Hex("Mufasa:"+realm+":Circle of Life") + ":" + nonce + ":" + cnonce)
+			ha2 := md5Hex("PUT:" + r.URL.RequestURI() + ":" + md5Hex(string(body)))
+
+			Expect(params["qop"]).To(Equal("auth-int"))
+			Expect(params["algorithm"]).To(Equal("MD5-sess"))
+			Expect(params["uri"]).To(Equal("/file%20name?a=1"))
+			Expect(params["response"]).To(Equal(md5Hex(ha1 + ":" + nonce + ":" + params["nc"] + ":" + cnonce + ":auth-int:" + ha2)))
+
+			fmt.Fprint(w, "ok")
+		}
+
+		seekerBody, err := SeekerBody(bytes.NewReader([]byte("body")))
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, body := range []io.Reader{strings.NewReader("body"), seekerBody} {
+			_, err := client.Request(&RequestData{
+				Method:         "PUT",
+				Path:           "/file name",
+				Params:         url.Values{"a": {"1"}},
+				ReqReader:      body,
+				ExpectedStatus: []int{http.StatusOK},
+				RespConsume:    true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(bodies).To(Equal([]string{"body", "body"}))
+	})
+
+	It("should quote parameters as quoted-string", func() {
+		// ISO-8859-1 and a tab are allowed in a quoted-string
+		auth.Username = "M\xfc\"fasa\\"
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.Header().Set("WWW-Authenticate", `Digest realm="r`+"\t"+`é\"alm", qop="auth", nonce="`+nonce+`"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+
+		_, err := client.Request(&RequestData{Method: "GET", Path: "/", ExpectedStatus: []int{http.StatusOK}, RespConsume: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		header := authorizationHeaders[1]
+		Expect(header).To(ContainSubstring(`username="M` + "\xfc" + `\"fasa\\"`))
+		Expect(header).To(ContainSubstring(`realm="r` + "\t" + `é\"alm"`))
+	})
+
+	It("should retry with new nonce when stale", func() {
+		currentNonce := "nonce1"
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			params := authorizations[len(authorizations)-1]
+
+			if params == nil || params["nonce"] != currentNonce {
+				stale := ""
+				if params != nil {
+					stale = ", stale=true"
+				}
+				w.Header().Set("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth", nonce="`+currentNonce+`"`+stale)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			fmt.Fprint(w, "ok")
+		}
+
+		_, err := client.Request(&RequestData{Method: "GET", Path: "/", ExpectedStatus: []int{http.StatusOK}, RespConsume: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		currentNonce = "nonce2"
+
+		_, err = client.Request(&RequestData{Method: "GET", Path: "/", ExpectedStatus: []int{http.StatusOK}, RespConsume: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(authorizations).To(HaveLen(4))
+		Expect(authorizations[3]["nonce"]).To(Equal("nonce2"))
+		Expect(authorizations[3]["nc"]).To(Equal("00000001"))
+	})
+
+	It("should fail with wrong password", func() {
+		auth.Password = "wrong"
+
+		handler = rfcHandler([]string{
+			`Digest realm="` + realm + `", qop="auth", algorithm=MD5, nonce="` + nonce + `"`,
+		}, "never")
+
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			ExpectedStatus: []int{http.StatusOK},
+		})
+		Expect(IsInvalidStatusCode(err, http.StatusUnauthorized)).To(BeTrue())
+		Expect(authorizations).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,285 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("Digest", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var handler func(http.ResponseWriter, *http.Request)
+
+	content := []byte("digest content")
+	sha256Sum := sha256.Sum256(content)
+	sha512Sum := sha512.Sum512(content)
+	md5Sum := md5.Sum(content)
+
+	sha256Digest := "sha-256=:" + base64.StdEncoding.EncodeToString(sha256Sum[:]) + ":"
+	sha512Digest := "sha-512=:" + base64.StdEncoding.EncodeToString(sha512Sum[:]) + ":"
+	md5Digest := base64.StdEncoding.EncodeToString(md5Sum[:])
+
+	BeforeEach(func() {
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			handler(w, r)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = HttpClient
+		client.BaseURL = u
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	Describe("ReqDigest", func() {
+		It("should set Content-Digest header for in-memory body", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Content-Digest")).To(Equal(sha256Digest))
+				body, _ := io.ReadAll(r.Body)
+				Expect(body).To(Equal(content))
+			}
+
+			_, err := client.Request(&RequestData{
+				Method:         "PUT",
+				Path:           "/",
+				ReqReader:      bytes.NewReader(content),
+				ReqDigest:      DigestSHA256,
+				ExpectedStatus: []int{http.StatusOK},
+				RespConsume:    true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should set Content-Digest header for replayable body", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Content-Digest")).To(Equal(sha512Digest))
+				Expect(r.ContentLength).To(Equal(int64(len(content))))
+			}
+
+			_, err := client.Request(&RequestData{
+				Method:         "PUT",
+				Path:           "/",
+				ReqReader:      BytesBody(content),
+				ReqDigest:      DigestSHA512,
+				ExpectedStatus: []int{http.StatusOK},
+				RespConsume:    true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should set Content-Digest header for seeker body", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Content-Digest")).To(Equal(sha256Digest))
+				body, _ := io.ReadAll(r.Body)
+				Expect(body).To(Equal(content))
+			}
+
+			body, err := SeekerBody(bytes.NewReader(content))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = client.Request(&RequestData{
+				Method:         "PUT",
+				Path:           "/",
+				ReqReader:      body,
+				ReqDigest:      DigestSHA256,
+				ExpectedStatus: []int{http.StatusOK},
+				RespConsume:    true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should send Content-Digest trailer for streamed body", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Content-Digest")).To(BeEmpty())
+				body, _ := io.ReadAll(r.Body)
+				Expect(body).To(Equal(content))
+				Expect(r.Trailer.Get("Content-Digest")).To(Equal(sha256Digest))
+			}
+
+			_, err := client.Request(&RequestData{
+				Method:         "PUT",
+				Path:           "/",
+				ReqReader:      &onlyReader{bytes.NewReader(content)},
+				ReqDigest:      DigestSHA256,
+				ExpectedStatus: []int{http.StatusOK},
+				RespConsume:    true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should send Content-Digest trailer for UploadFile", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				sum := sha256.Sum256(body)
+				Expect(r.Trailer.Get("Content-Digest")).To(Equal("sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"))
+			}
+
+			req := &RequestData{
+				Method:         "POST",
+				Path:           "/",
+				ReqDigest:      DigestSHA256,
+				ExpectedStatus: []int{http.StatusOK},
+				RespConsume:    true,
+			}
+
+			err := req.UploadFile("file", "file.txt", &onlyReader{bytes.NewReader(content)})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = client.Request(req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should set legacy Content-MD5 header", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Content-MD5")).To(Equal(md5Digest))
+			}
+
+			_, err := client.Request(&RequestData{
+				Method:         "PUT",
+				Path:           "/",
+				ReqReader:      bytes.NewReader(content),
+				ReqDigest:      DigestMD5,
+				ExpectedStatus: []int{http.StatusOK},
+				RespConsume:    true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should fail with invalid ReqDigest", func() {
+			_, err := client.Request(&RequestData{
+				Method:    "PUT",
+				Path:      "/",
+				ReqReader: bytes.NewReader(content),
+				ReqDigest: "crc32",
+			})
+			Expect(err).To(MatchError("HTTPClient: invalid ReqDigest: crc32"))
+		})
+	})
+
+	Describe("VerifyDigest", func() {
+		get := func() ([]byte, error) {
+			res, err := client.Request(&RequestData{
+				Method:         "GET",
+				Path:           "/",
+				VerifyDigest:   true,
+				ExpectedStatus: []int{http.StatusOK, http.StatusPartialContent},
+			})
+			if err != nil {
+				return nil, err
+			}
+			defer res.Body.Close()
+			return io.ReadAll(res.Body)
+		}
+
+		DescribeTable("should verify response digest",
+			func(header string, value string) {
+				handler = func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set(header, value)
+					w.Write(content)
+				}
+
+				body, err := get()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(body).To(Equal(content))
+
+				handler = func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set(header, value)
+					w.Write([]byte("tampered"))
+				}
+
+				_, err = get()
+				ie, ok := err.(IntegrityError)
+				Expect(ok).To(BeTrue())
+				Expect(ie.Header).To(Equal(header))
+			},
+			Entry("Content-Digest sha-256", "Content-Digest", sha256Digest),
+			Entry("Content-Digest sha-512", "Content-Digest", sha512Digest),
+			Entry("Repr-Digest", "Repr-Digest", sha512Digest+", sha-256=:"+base64.StdEncoding.EncodeToString(sha256Sum[:])+":"),
+			Entry("Content-MD5", "Content-MD5", md5Digest),
+		)
+
+		It("should verify Content-Digest trailer", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Trailer", "Content-Digest")
+				w.Write([]byte("tampered"))
+				w.Header().Set("Content-Digest", sha256Digest)
+			}
+
+			_, err := get()
+			ie, ok := err.(IntegrityError)
+			Expect(ok).To(BeTrue())
+			Expect(ie.Algorithm).To(Equal(DigestSHA256))
+			Expect(ie.Expected).To(Equal(sha256Sum[:]))
+		})
+
+		It("should ignore Repr-Digest for partial content", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Repr-Digest", sha256Digest)
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			}
+
+			res, err := client.Request(&RequestData{
+				Method:         "GET",
+				Path:           "/",
+				Headers:        http.Header{"Range": {"bytes=0-5"}},
+				VerifyDigest:   true,
+				ExpectedStatus: []int{http.StatusPartialContent},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(body).To(Equal(content[:6]))
+		})
+
+		It("should verify Content-Digest of compressed content", func() {
+			var buf bytes.Buffer
+			gw := gzip.NewWriter(&buf)
+			gw.Write(content)
+			gw.Close()
+			gzipped := buf.Bytes()
+			sum := sha256.Sum256(gzipped)
+
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Header().Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+				w.Write(gzipped)
+			}
+
+			body, err := get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(body).To(Equal(content))
+		})
+
+		It("should not verify without digest headers", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(strings.Repeat("x", 10)))
+			}
+
+			body, err := get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(body).To(HaveLen(10))
+		})
+	})
+})
@@ -0,0 +1,328 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DownloadOptions struct {
+	MaxRetries      int           // consecutive failed attempts without progress
+	RetryBackoff    time.Duration // delay before the first retry, doubled after each failure
+	MaxRetryBackoff time.Duration
+}
+
+var DefaultDownloadOptions = DownloadOptions{
+	MaxRetries:      5,
+	RetryBackoff:    1 * time.Second,
+	MaxRetryBackoff: 30 * time.Second,
+}
+
+func (o *DownloadOptions) backoff(failures int) time.Duration {
+	return retryBackoff(o.RetryBackoff, o.MaxRetryBackoff, failures)
+}
+
+func retryBackoff(initial time.Duration, max time.Duration, failures int) time.Duration {
+	d := initial
+
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+
+	if max > 0 && d > max {
+		d = max
+	}
+
+	return d
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	if ctx == nil {
+		time.Sleep(d)
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isRetryableError(ctx context.Context, err error) bool {
+	if ctx != nil && ctx.Err() != nil {
+		return false
+	}
+
+	if _, ok := IsStalledTransferError(err); ok {
+		return true
+	}
+
+	if errors.Is(err, ContentChangedError) {
+		return false
+	}
+
+	if ise, ok := IsInvalidStatusError(err); ok {
+		return ise.Got >= 500 || ise.Got == http.StatusTooManyRequests || ise.Got == http.StatusRequestTimeout
+	}
+
+	return true
+}
+
+// parseContentRange parses "bytes start-end/size". size is -1 if unknown.
+func parseContentRange(header string) (start int64, end int64, size int64, err error) {
+	invalid := fmt.Errorf("HTTPClient: invalid Content-Range: %s", header)
+
+	rest, ok := strings.CutPrefix(header, "bytes ")
+
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+
+	rng, sizeStr, ok := strings.Cut(rest, "/")
+
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+
+	startStr, endStr, ok := strings.Cut(rng, "-")
+
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+
+	if start, err = strconv.ParseInt(startStr, 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+
+	if end, err = strconv.ParseInt(endStr, 10, 64); err != nil || end < start {
+		return 0, 0, 0, invalid
+	}
+
+	if sizeStr == "*" {
+		return start, end, -1, nil
+	}
+
+	if size, err = strconv.ParseInt(sizeStr, 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+
+	return start, end, size, nil
+}
+
+// responseValidator returns a value usable in If-Range. Weak ETags cannot be
+// used for range requests.
+func responseValidator(res *http.Response) string {
+	if etag := res.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return res.Header.Get("Last-Modified")
+}
+
+type truncater interface {
+	Truncate(size int64) error
+}
+
+func (c *HTTPClient) copyRequest(req *RequestData) (nr *RequestData, err error) {
+	ok, nr := req.Copy()
+
+	if !ok {
+		return nil, fmt.Errorf("HTTPClient: request with ReqReader cannot be repeated")
+	}
+
+	nr.Context = req.Context
+
+	if nr.Headers == nil {
+		nr.Headers = make(http.Header)
+	}
+
+	return nr, nil
+}
+
+// Download writes the response body to w and resumes the transfer with a Range
+// request if it fails mid-stream. It returns the number of bytes written.
+func (c *HTTPClient) Download(req *RequestData, w io.WriterAt, opts *DownloadOptions) (n int64, err error) {
+	if !req.CanCopy() {
+		return 0, fmt.Errorf("HTTPClient: request with ReqReader cannot be repeated")
+	}
+
+	if opts == nil {
+		opts = &DefaultDownloadOptions
+	}
+
+	d := &download{
+		c:    c,
+		req:  req,
+		w:    w,
+		opts: opts,
+		end:  -1,
+		size: -1,
+	}
+
+	err = d.run()
+
+	return d.offset, err
+}
+
+type download struct {
+	c         *HTTPClient
+	req       *RequestData
+	w         io.WriterAt
+	opts      *DownloadOptions
+	offset    int64
+	end       int64 // inclusive, -1 if open ended
+	size      int64 // -1 if unknown
+	validator string
+}
+
+func (d *download) run() (err error) {
+	failures := 0
+
+	for {
+		written, complete, err := d.attempt()
+
+		if err == nil && complete {
+			return nil
+		}
+
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+
+		if !isRetryableError(d.req.Context, err) {
+			return err
+		}
+
+		if written > 0 {
+			failures = 0
+		}
+
+		failures++
+
+		if failures > d.opts.MaxRetries {
+			return err
+		}
+
+		if err := sleepContext(d.req.Context, d.opts.backoff(failures)); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *download) attempt() (written int64, complete bool, err error) {
+	r, err := d.c.copyRequest(d.req)
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	r.DisableDecompression = true
+	r.ExpectedStatus = []int{http.StatusOK, http.StatusPartialContent}
+	r.RespEncoding = ""
+	r.RespValue = nil
+	r.RespConsume = false
+
+	if d.end >= 0 {
+		r.Headers.Set("Range", fmt.Sprintf("bytes=%d-%d", d.offset, d.end))
+	} else if d.offset > 0 {
+		r.Headers.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+	}
+
+	if r.Headers.Get("Range") != "" && d.validator != "" {
+		r.Headers.Set("If-Range", d.validator)
+	}
+
+	res, err := d.c.Request(r)
+
+	if err != nil {
+		if IsInvalidStatusCode(err, http.StatusRequestedRangeNotSatisfiable) && d.size >= 0 && d.offset == d.size {
+			return 0, true, nil
+		}
+
+		return 0, false, err
+	}
+
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		if d.end >= 0 {
+			return 0, false, ContentChangedError
+		}
+
+		if d.offset > 0 {
+			// the server ignored the range or the content has changed
+			d.offset = 0
+
+			if t, ok := d.w.(truncater); ok {
+				if err = t.Truncate(0); err != nil {
+					return 0, false, err
+				}
+			}
+		}
+
+		d.size = res.ContentLength
+		d.validator = responseValidator(res)
+
+	case http.StatusPartialContent:
+		start, _, total, err := parseContentRange(res.Header.Get("Content-Range"))
+
+		if err != nil {
+			return 0, false, err
+		}
+
+		if start != d.offset {
+			return 0, false, fmt.Errorf("HTTPClient: unexpected Content-Range start %d, expected %d", start, d.offset)
+		}
+
+		if d.end >= 0 && total >= 0 && total != d.size {
+			return 0, false, ContentChangedError
+		}
+
+		if total >= 0 {
+			d.size = total
+		}
+
+		if d.validator == "" {
+			d.validator = responseValidator(res)
+		}
+	}
+
+	var body io.Reader = res.Body
+
+	if d.end >= 0 {
+		body = io.LimitReader(body, d.end-d.offset+1)
+	}
+
+	written, err = io.Copy(io.NewOffsetWriter(d.w, d.offset), body)
+
+	d.offset += written
+
+	if err != nil {
+		return written, false, err
+	}
+
+	if d.end >= 0 {
+		return written, d.offset > d.end, nil
+	}
+
+	if d.size >= 0 && d.offset < d.size {
+		return written, false, nil
+	}
+
+	return written, true, nil
+}
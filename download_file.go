@@ -0,0 +1,203 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type DownloadToFileOptions struct {
+	HashAlgorithm         string // DigestSHA256 if empty
+	ExpectedHash          []byte
+	UseResponseDigest     bool // expected hash from Repr-Digest, Content-Digest, Digest or ETag, MissingDigestError without one
+	UseContentDisposition bool // destination is a directory, file name is taken from the response
+}
+
+func responseDigest(res *http.Response, algorithm string, size int) (sum []byte, ok bool) {
+	for _, header := range []string{"Repr-Digest", "Digest", "Content-Digest"} {
+		if sum, ok = parseDigestFields(res.Header.Get(header))[algorithm]; ok {
+			return sum, true
+		}
+	}
+
+	return parseETagDigest(res.Header.Get("ETag"), size)
+}
+
+func responseFileName(res *http.Response) (name string, err error) {
+	if cd := res.Header.Get("Content-Disposition"); cd != "" {
+		if _, params, err := mime.ParseMediaType(cd); err == nil {
+			name = params["filename"]
+		}
+	}
+
+	if name == "" && res.Request != nil {
+		if u, err := parseRequestURL(res.Request.URL); err == nil {
+			name = path.Base(u.Path)
+		}
+	}
+
+	name = path.Base(path.Clean("/" + strings.ReplaceAll(name, "\\", "/")))
+
+	if name == "/" || name == "." || name == ".." {
+		return "", fmt.Errorf("HTTPClient: could not determine download file name")
+	}
+
+	return name, nil
+}
+
+// createTempFile creates a file like os.Create does, with mode 0666 before
+// umask, and not 0600 like os.CreateTemp, since it is renamed to the
+// destination.
+func createTempFile(dir string, prefix string) (f *os.File, err error) {
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+
+		f, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+
+		if !os.IsExist(err) {
+			return f, err
+		}
+	}
+
+	return nil, err
+}
+
+// DownloadToFile writes the response body to a temporary file, verifies its
+// hash and renames it to dst. The temporary file is removed on error.
+func (c *HTTPClient) DownloadToFile(req *RequestData, dst string, opts *DownloadToFileOptions) (filePath string, err error) {
+	if opts == nil {
+		opts = &DownloadToFileOptions{}
+	}
+
+	algorithm := opts.HashAlgorithm
+
+	if algorithm == "" {
+		algorithm = DigestSHA256
+	}
+
+	newHash, ok := digestAlgorithms[algorithm]
+
+	if !ok {
+		return "", fmt.Errorf("HTTPClient: invalid HashAlgorithm: %s", algorithm)
+	}
+
+	dir := filepath.Dir(dst)
+
+	if opts.UseContentDisposition {
+		dir = dst
+	}
+
+	r := *req
+	r.RespEncoding = ""
+	r.RespValue = nil
+	r.RespConsume = false
+
+	// the response digests cover the content-coded representation, it is
+	// hashed before it is decompressed
+	var encodedHash hash.Hash
+
+	if opts.UseResponseDigest && opts.ExpectedHash == nil {
+		r.beforeDecompress = func(res *http.Response) {
+			// the transport decompressed the body
+			if res.Uncompressed {
+				return
+			}
+
+			encodedHash = newHash()
+
+			res.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(res.Body, encodedHash), res.Body}
+		}
+	}
+
+	if r.ExpectedStatus == nil {
+		r.ExpectedStatus = []int{http.StatusOK}
+	}
+
+	res, err := c.Request(&r)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+
+	if opts.UseContentDisposition {
+		name, err := responseFileName(res)
+
+		if err != nil {
+			return "", err
+		}
+
+		dst = filepath.Join(dir, name)
+	}
+
+	f, err := createTempFile(dir, ".download-")
+
+	if err != nil {
+		return "", err
+	}
+
+	tmpPath := f.Name()
+
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	h := newHash()
+
+	if _, err = io.Copy(io.MultiWriter(f, h), res.Body); err != nil {
+		return "", err
+	}
+
+	if err = f.Sync(); err != nil {
+		return "", err
+	}
+
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+
+	expected := opts.ExpectedHash
+
+	if expected == nil && opts.UseResponseDigest {
+		if encodedHash == nil {
+			return "", MissingDigestError
+		}
+
+		if expected, ok = responseDigest(res, algorithm, h.Size()); !ok {
+			return "", MissingDigestError
+		}
+
+		h = encodedHash
+	}
+
+	if expected != nil {
+		if sum := h.Sum(nil); !bytes.Equal(sum, expected) {
+			return "", ChecksumError{
+				Expected: expected,
+				Actual:   sum,
+			}
+		}
+	}
+
+	if err = os.Rename(tmpPath, dst); err != nil {
+		return "", err
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,279 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("DownloadToFile", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var handler func(http.ResponseWriter, *http.Request)
+	var dir string
+
+	content := []byte("file content")
+	sha256Sum := sha256.Sum256(content)
+	sha512Sum := sha512.Sum512(content)
+	md5Sum := md5.Sum(content)
+
+	BeforeEach(func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Write(content)
+		}
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			handler(w, r)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+
+		dir = GinkgoT().TempDir()
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	dirEntries := func() []string {
+		entries, err := os.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		names := []string{}
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+
+		return names
+	}
+
+	It("should download to file with expected hash", func() {
+		dst := filepath.Join(dir, "file.txt")
+
+		path, err := client.DownloadToFile(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, dst, &DownloadToFileOptions{
+			ExpectedHash: sha256Sum[:],
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal(dst))
+
+		data, err := os.ReadFile(dst)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(content))
+		Expect(dirEntries()).To(Equal([]string{"file.txt"}))
+	})
+
+	It("should create file with the permissions of os.Create", func() {
+		dst := filepath.Join(dir, "file.txt")
+
+		_, err := client.DownloadToFile(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, dst, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		f, err := os.Create(filepath.Join(dir, "created.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		f.Close()
+
+		info, err := os.Stat(dst)
+		Expect(err).NotTo(HaveOccurred())
+		created, err := os.Stat(f.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode()).To(Equal(created.Mode()))
+	})
+
+	It("should remove temporary file on hash mismatch", func() {
+		_, err := client.DownloadToFile(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, filepath.Join(dir, "file.txt"), &DownloadToFileOptions{
+			ExpectedHash: make([]byte, 32),
+		})
+		Expect(err).To(Equal(ChecksumError{
+			Expected: make([]byte, 32),
+			Actual:   sha256Sum[:],
+		}))
+		Expect(dirEntries()).To(BeEmpty())
+	})
+
+	It("should remove temporary file on read error", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "100")
+			w.Write(content)
+		}
+
+		_, err := client.DownloadToFile(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, filepath.Join(dir, "file.txt"), nil)
+		Expect(err).To(HaveOccurred())
+		Expect(dirEntries()).To(BeEmpty())
+	})
+
+	It("should not create file on invalid status", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		_, err := client.DownloadToFile(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, filepath.Join(dir, "file.txt"), nil)
+		Expect(IsInvalidStatusCode(err, http.StatusNotFound)).To(BeTrue())
+		Expect(dirEntries()).To(BeEmpty())
+	})
+
+	DescribeTable("should verify hash from response headers",
+		func(header string, value string, algorithm string) {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(header, value)
+				w.Write(content)
+			}
+
+			_, err := client.DownloadToFile(&RequestData{
+				Method: "GET",
+				Path:   "/file",
+			}, filepath.Join(dir, "file.txt"), &DownloadToFileOptions{
+				HashAlgorithm:     algorithm,
+				UseResponseDigest: true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(header, value)
+				w.Write([]byte("tampered"))
+			}
+
+			_, err = client.DownloadToFile(&RequestData{
+				Method: "GET",
+				Path:   "/file",
+			}, filepath.Join(dir, "file.txt"), &DownloadToFileOptions{
+				HashAlgorithm:     algorithm,
+				UseResponseDigest: true,
+			})
+			_, ok := err.(ChecksumError)
+			Expect(ok).To(BeTrue())
+		},
+		Entry("Content-Digest", "Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sha256Sum[:])+":", DigestSHA256),
+		Entry("Repr-Digest", "Repr-Digest", "sha-512=:"+base64.StdEncoding.EncodeToString(sha512Sum[:])+":, sha-256=:AAAA:", DigestSHA512),
+		Entry("Digest", "Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sha256Sum[:]), DigestSHA256),
+		Entry("ETag", "ETag", `"`+hex.EncodeToString(md5Sum[:])+`"`, DigestMD5),
+	)
+
+	It("should fail without response digest", func() {
+		_, err := client.DownloadToFile(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, filepath.Join(dir, "file.txt"), &DownloadToFileOptions{
+			UseResponseDigest: true,
+		})
+		Expect(err).To(Equal(MissingDigestError))
+		Expect(dirEntries()).To(BeEmpty())
+	})
+
+	It("should verify hash of content-coded response", func() {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		gw.Write(content)
+		gw.Close()
+		encoded := buf.Bytes()
+		encodedSum := sha256.Sum256(encoded)
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Accept-Encoding")).To(Equal(AcceptEncoding))
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(encodedSum[:])+":")
+			w.Write(encoded)
+		}
+
+		dst := filepath.Join(dir, "file.txt")
+
+		_, err := client.DownloadToFile(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, dst, &DownloadToFileOptions{
+			UseResponseDigest: true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := os.ReadFile(dst)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(content))
+	})
+
+	It("should fail if the transport decompressed the response", func() {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		gw.Write(content)
+		gw.Close()
+		encodedSum := sha256.Sum256(buf.Bytes())
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(encodedSum[:])+":")
+			w.Write(buf.Bytes())
+		}
+
+		// the transport of ts.Client() requests and decompresses gzip itself
+		client.DisableDecompression()
+
+		_, err := client.DownloadToFile(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, filepath.Join(dir, "file.txt"), &DownloadToFileOptions{
+			UseResponseDigest: true,
+		})
+		Expect(err).To(Equal(MissingDigestError))
+		Expect(dirEntries()).To(BeEmpty())
+	})
+
+	It("should use file name from Content-Disposition", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Disposition", `attachment; filename="../../report.pdf"`)
+			w.Write(content)
+		}
+
+		path, err := client.DownloadToFile(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, dir, &DownloadToFileOptions{
+			UseContentDisposition: true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal(filepath.Join(dir, "report.pdf")))
+		Expect(dirEntries()).To(Equal([]string{"report.pdf"}))
+	})
+
+	It("should use file name from URL without Content-Disposition", func() {
+		path, err := client.DownloadToFile(&RequestData{
+			Method: "GET",
+			Path:   "/files/data.bin",
+		}, dir, &DownloadToFileOptions{
+			UseContentDisposition: true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal(filepath.Join(dir, "data.bin")))
+	})
+})
@@ -0,0 +1,188 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"sync"
+)
+
+type SegmentedDownloadOptions struct {
+	DownloadOptions
+	Segments         int
+	MinSegmentSize   int64
+	Checksum         func() hash.Hash
+	ExpectedChecksum []byte
+}
+
+var DefaultSegmentedDownloadOptions = SegmentedDownloadOptions{
+	DownloadOptions: DefaultDownloadOptions,
+	Segments:        4,
+	MinSegmentSize:  1024 * 1024,
+}
+
+func (o *SegmentedDownloadOptions) verifyChecksum(w io.WriterAt, size int64) (err error) {
+	if o.Checksum == nil || o.ExpectedChecksum == nil {
+		return nil
+	}
+
+	ra, ok := w.(io.ReaderAt)
+
+	if !ok {
+		return fmt.Errorf("HTTPClient: checksum verification requires io.ReaderAt")
+	}
+
+	h := o.Checksum()
+
+	if _, err = io.Copy(h, io.NewSectionReader(ra, 0, size)); err != nil {
+		return err
+	}
+
+	sum := h.Sum(nil)
+
+	if !bytes.Equal(sum, o.ExpectedChecksum) {
+		return ChecksumError{
+			Expected: o.ExpectedChecksum,
+			Actual:   sum,
+		}
+	}
+
+	return nil
+}
+
+// DownloadSegmented fetches the content in several ranges concurrently and
+// writes them into w at their offsets. It falls back to Download if the server
+// does not support range requests.
+func (c *HTTPClient) DownloadSegmented(req *RequestData, w io.WriterAt, opts *SegmentedDownloadOptions) (n int64, err error) {
+	if !req.CanCopy() {
+		return 0, fmt.Errorf("HTTPClient: request with ReqReader cannot be repeated")
+	}
+
+	if opts == nil {
+		opts = &DefaultSegmentedDownloadOptions
+	}
+
+	head, err := c.copyRequest(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	head.Method = "HEAD"
+	head.DisableDecompression = true
+	head.ExpectedStatus = []int{http.StatusOK}
+	head.RespEncoding = ""
+	head.RespValue = nil
+	head.RespConsume = true
+
+	res, err := c.Request(head)
+
+	if err != nil {
+		return 0, err
+	}
+
+	size := res.ContentLength
+	segments := opts.Segments
+
+	// each segment holds a connection for the whole transfer, not just for the
+	// duration of Request
+	if c.rateLimited && segments > cap(c.rateLimitChan) {
+		segments = cap(c.rateLimitChan)
+	}
+
+	if opts.MinSegmentSize > 0 && size > 0 {
+		if maxSegments := (size + opts.MinSegmentSize - 1) / opts.MinSegmentSize; int64(segments) > maxSegments {
+			segments = int(maxSegments)
+		}
+	}
+
+	// every segment has at least one byte
+	if size > 0 && int64(segments) > size {
+		segments = int(size)
+	}
+
+	if res.Header.Get("Accept-Ranges") != "bytes" || size <= 0 || segments < 2 {
+		if n, err = c.Download(req, w, &opts.DownloadOptions); err != nil {
+			return n, err
+		}
+
+		return n, opts.verifyChecksum(w, n)
+	}
+
+	ctx := req.Context
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	segmentReq, err := c.copyRequest(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	segmentReq.Context = ctx
+
+	validator := responseValidator(res)
+	segmentSize := size / int64(segments)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var firstErr error
+
+	for i := 0; i < segments; i++ {
+		start := int64(i) * segmentSize
+		end := start + segmentSize - 1
+
+		if i == segments-1 {
+			end = size - 1
+		}
+
+		d := &download{
+			c:         c,
+			req:       segmentReq,
+			w:         w,
+			opts:      &opts.DownloadOptions,
+			offset:    start,
+			end:       end,
+			size:      size,
+			validator: validator,
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := d.run()
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			n += d.offset - start
+
+			if err != nil && firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return n, firstErr
+	}
+
+	if n != size {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, opts.verifyChecksum(w, n)
+}
@@ -0,0 +1,229 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("DownloadSegmented", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var handler func(http.ResponseWriter, *http.Request)
+	var file *os.File
+	var opts *SegmentedDownloadOptions
+	var mutex sync.Mutex
+	var ranges []string
+
+	BeforeEach(func() {
+		ranges = nil
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" {
+				mutex.Lock()
+				ranges = append(ranges, r.Header.Get("Range"))
+				mutex.Unlock()
+			}
+
+			serveContent(w, r, 0)
+		}
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			handler(w, r)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+
+		var err error
+		file, err = os.Create(filepath.Join(GinkgoT().TempDir(), "download"))
+		Expect(err).NotTo(HaveOccurred())
+
+		opts = &SegmentedDownloadOptions{
+			DownloadOptions: DownloadOptions{
+				MaxRetries:      3,
+				RetryBackoff:    time.Millisecond,
+				MaxRetryBackoff: 10 * time.Millisecond,
+			},
+			Segments:       4,
+			MinSegmentSize: 1000,
+		}
+	})
+
+	AfterEach(func() {
+		file.Close()
+		ts.Close()
+	})
+
+	readFile := func() []byte {
+		data, err := os.ReadFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		return data
+	}
+
+	It("should download the content in segments", func() {
+		n, err := client.DownloadSegmented(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(len(downloadContent))))
+		Expect(readFile()).To(Equal(downloadContent))
+
+		sort.Strings(ranges)
+		Expect(ranges).To(Equal([]string{
+			"bytes=0-24999",
+			"bytes=25000-49999",
+			"bytes=50000-74999",
+			"bytes=75000-99999",
+		}))
+	})
+
+	It("should limit segments by minimum segment size and rate limit", func() {
+		opts.MinSegmentSize = 40000
+
+		n, err := client.DownloadSegmented(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(len(downloadContent))))
+		Expect(ranges).To(HaveLen(3))
+
+		ranges = nil
+		client.SetRateLimit(2, 0)
+
+		n, err = client.DownloadSegmented(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(len(downloadContent))))
+		Expect(ranges).To(HaveLen(2))
+	})
+
+	It("should not use more segments than bytes", func() {
+		opts.MinSegmentSize = 0
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" {
+				mutex.Lock()
+				ranges = append(ranges, r.Header.Get("Range"))
+				mutex.Unlock()
+			}
+
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader([]byte("abc")))
+		}
+
+		n, err := client.DownloadSegmented(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(3)))
+		Expect(readFile()).To(Equal([]byte("abc")))
+
+		sort.Strings(ranges)
+		Expect(ranges).To(Equal([]string{"bytes=0-0", "bytes=1-1", "bytes=2-2"}))
+	})
+
+	It("should retry failed segments", func() {
+		failed := false
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			fail := !failed && r.Header.Get("Range") == "bytes=25000-49999"
+			if fail {
+				failed = true
+			}
+			mutex.Unlock()
+
+			if fail {
+				serveContent(w, r, 1000)
+			} else {
+				serveContent(w, r, 0)
+			}
+		}
+
+		n, err := client.DownloadSegmented(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(len(downloadContent))))
+		Expect(readFile()).To(Equal(downloadContent))
+	})
+
+	It("should fall back to single download without range support", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Range")).To(BeEmpty())
+			w.Write(downloadContent)
+		}
+
+		n, err := client.DownloadSegmented(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(len(downloadContent))))
+		Expect(readFile()).To(Equal(downloadContent))
+	})
+
+	It("should verify checksum", func() {
+		sum := sha256.Sum256(downloadContent)
+
+		opts.Checksum = sha256.New
+		opts.ExpectedChecksum = sum[:]
+
+		_, err := client.DownloadSegmented(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+
+		opts.ExpectedChecksum = make([]byte, 32)
+
+		_, err = client.DownloadSegmented(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).To(Equal(ChecksumError{
+			Expected: make([]byte, 32),
+			Actual:   sum[:],
+		}))
+	})
+
+	It("should fail if content changes", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "HEAD" {
+				serveContent(w, r, 0)
+				return
+			}
+
+			w.Header().Set("ETag", `"changed"`)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(downloadContent))
+		}
+
+		_, err := client.DownloadSegmented(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).To(Equal(ContentChangedError))
+	})
+})
@@ -0,0 +1,232 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var downloadContent = bytes.Repeat([]byte("0123456789"), 10000)
+
+// serveContent serves downloadContent with range support and aborts the
+// connection after limit bytes if limit is positive.
+func serveContent(w http.ResponseWriter, r *http.Request, limit int) {
+	w.Header().Set("ETag", `"etag"`)
+
+	if limit <= 0 {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(downloadContent))
+		return
+	}
+
+	http.ServeContent(&abortingWriter{ResponseWriter: w, limit: limit}, r, "", time.Time{}, bytes.NewReader(downloadContent))
+}
+
+type abortingWriter struct {
+	http.ResponseWriter
+	limit   int
+	written int
+}
+
+func (w *abortingWriter) Write(p []byte) (n int, err error) {
+	if w.written+len(p) > w.limit {
+		p = p[:w.limit-w.written]
+		w.ResponseWriter.Write(p)
+		w.ResponseWriter.(http.Flusher).Flush()
+
+		conn, _, err := w.ResponseWriter.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+
+		return 0, http.ErrHijacked
+	}
+
+	n, err = w.ResponseWriter.Write(p)
+	w.written += n
+
+	return n, err
+}
+
+var _ = Describe("Download", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var handler func(http.ResponseWriter, *http.Request)
+	var file *os.File
+	var opts *DownloadOptions
+
+	BeforeEach(func() {
+		handler = nil
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			handler(w, r)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+
+		var err error
+		file, err = os.Create(filepath.Join(GinkgoT().TempDir(), "download"))
+		Expect(err).NotTo(HaveOccurred())
+
+		opts = &DownloadOptions{
+			MaxRetries:      3,
+			RetryBackoff:    time.Millisecond,
+			MaxRetryBackoff: 10 * time.Millisecond,
+		}
+	})
+
+	AfterEach(func() {
+		file.Close()
+		ts.Close()
+	})
+
+	readFile := func() []byte {
+		data, err := os.ReadFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		return data
+	}
+
+	It("should download the content", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			serveContent(w, r, 0)
+		}
+
+		n, err := client.Download(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(len(downloadContent))))
+		Expect(readFile()).To(Equal(downloadContent))
+	})
+
+	It("should resume interrupted download", func() {
+		var requests []http.Header
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Header.Clone())
+
+			if len(requests) <= 2 {
+				serveContent(w, r, 30000)
+			} else {
+				serveContent(w, r, 0)
+			}
+		}
+
+		n, err := client.Download(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(len(downloadContent))))
+		Expect(readFile()).To(Equal(downloadContent))
+
+		Expect(requests).To(HaveLen(3))
+		Expect(requests[0].Get("Range")).To(BeEmpty())
+		Expect(requests[1].Get("Range")).To(Equal("bytes=30000-"))
+		Expect(requests[1].Get("If-Range")).To(Equal(`"etag"`))
+		Expect(requests[2].Get("Range")).To(Equal("bytes=60000-"))
+	})
+
+	It("should restart if server ignores range", func() {
+		var count int32
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&count, 1) == 1 {
+				serveContent(w, r, 50000)
+			} else {
+				r.Header.Del("Range")
+				serveContent(w, r, 0)
+			}
+		}
+
+		file.Write(bytes.Repeat([]byte("x"), 200000))
+
+		n, err := client.Download(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(len(downloadContent))))
+		Expect(readFile()).To(Equal(downloadContent))
+	})
+
+	It("should restart if content changed", func() {
+		var count int32
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&count, 1) == 1 {
+				serveContent(w, r, 50000)
+			} else {
+				Expect(r.Header.Get("If-Range")).To(Equal(`"etag"`))
+				w.Header().Set("ETag", `"changed"`)
+				http.ServeContent(w, r, "", time.Time{}, strings.NewReader("changed"))
+			}
+		}
+
+		n, err := client.Download(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(7)))
+		Expect(string(readFile())).To(Equal("changed"))
+	})
+
+	It("should fail after max retries", func() {
+		var count int32
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&count, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		_, err := client.Download(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(IsInvalidStatusCode(err, http.StatusServiceUnavailable)).To(BeTrue())
+		Expect(int(count)).To(Equal(4))
+	})
+
+	It("should not retry client errors", func() {
+		var count int32
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&count, 1)
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		_, err := client.Download(&RequestData{
+			Method: "GET",
+			Path:   "/file",
+		}, file, opts)
+		Expect(IsInvalidStatusCode(err, http.StatusNotFound)).To(BeTrue())
+		Expect(int(count)).To(Equal(1))
+	})
+
+	It("should not download request with reader", func() {
+		_, err := client.Download(&RequestData{
+			Method:    "POST",
+			Path:      "/file",
+			ReqReader: strings.NewReader("body"),
+		}, file, opts)
+		Expect(err).To(Equal(fmt.Errorf("HTTPClient: request with ReqReader cannot be repeated")))
+	})
+})
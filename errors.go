@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 type InvalidStatusError struct {
@@ -36,3 +37,112 @@ func IsInvalidStatusCode(err error, statusCode int) bool {
 }
 
 var RateLimitTimeoutError = errors.New("HTTPClient rate limit timeout")
+
+var ContentChangedError = errors.New("HTTPClient content changed during download")
+
+var MissingDigestError = errors.New("HTTPClient response has no digest")
+
+type ChecksumError struct {
+	Expected []byte
+	Actual   []byte
+}
+
+func (e ChecksumError) Error() string {
+	return fmt.Sprintf("Checksum mismatch! Got %x, expected %x", e.Actual, e.Expected)
+}
+
+// OAuth2Error is an error response from the OAuth2 token endpoint.
+type OAuth2Error struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e OAuth2Error) Error() string {
+	return fmt.Sprintf("OAuth2 error! Got %d, error: %s, description: %s", e.StatusCode, e.Code, e.Description)
+}
+
+// IntegrityError is returned when the response body does not match the digest
+// in Header.
+type IntegrityError struct {
+	Header    string
+	Algorithm string
+	Expected  []byte
+	Actual    []byte
+}
+
+func (e IntegrityError) Error() string {
+	return fmt.Sprintf("Integrity check failed! %s %s got %x, expected %x", e.Header, e.Algorithm, e.Actual, e.Expected)
+}
+
+// StalledTransferError is returned when no data was transferred for
+// IdleTimeout or the throughput was below MinThroughput during Window.
+type StalledTransferError struct {
+	IdleTimeout   time.Duration
+	Throughput    float64
+	MinThroughput int64
+	Window        time.Duration
+}
+
+func (e StalledTransferError) Error() string {
+	if e.IdleTimeout > 0 {
+		return fmt.Sprintf("Transfer stalled! No data transferred for %s", e.IdleTimeout)
+	}
+
+	return fmt.Sprintf("Transfer stalled! Throughput %.0f B/s, expected at least %d B/s over %s", e.Throughput, e.MinThroughput, e.Window)
+}
+
+func IsStalledTransferError(err error) (stalledTransferError *StalledTransferError, ok bool) {
+	var ste StalledTransferError
+
+	if errors.As(err, &ste) {
+		return &ste, true
+	}
+
+	var stePtr *StalledTransferError
+
+	if errors.As(err, &stePtr) {
+		return stePtr, true
+	}
+
+	return nil, false
+}
+
+// SignatureError is returned when a message signature (RFC 9421) is missing or
+// invalid.
+type SignatureError struct {
+	Label  string
+	Reason string
+}
+
+func (e SignatureError) Error() string {
+	return fmt.Sprintf("Signature verification failed! label: %s, reason: %s", e.Label, e.Reason)
+}
+
+// PinningError is returned when no server certificate matches the pinned
+// public keys.
+type PinningError struct {
+	Host   string
+	Pins   []string
+	Actual []string // pins of the peer certificates
+}
+
+func (e PinningError) Error() string {
+	return fmt.Sprintf("Certificate pinning failed! host: %s, got %v, expected %v", e.Host, e.Actual, e.Pins)
+}
+
+func IsPinningError(err error) (pinningError *PinningError, ok bool) {
+	var pe PinningError
+
+	if errors.As(err, &pe) {
+		return &pe, true
+	}
+
+	var pePtr *PinningError
+
+	if errors.As(err, &pePtr) {
+		return pePtr, true
+	}
+
+	return nil, false
+}
@@ -60,3 +60,25 @@ var _ = Describe("InvalidStatusError", func() {
 		})
 	})
 })
+
+var _ = Describe("PinningError", func() {
+	Describe("IsPinningError", func() {
+		It("should check if value is PinningError", func() {
+			err := fmt.Errorf("tls: %w", PinningError{Host: "example.com"})
+
+			pinningErr, ok := IsPinningError(err)
+			Expect(ok).To(BeTrue())
+			Expect(pinningErr.Host).To(Equal("example.com"))
+		})
+
+		It("should check if pointer is PinningError", func() {
+			err := &PinningError{Host: "example.com"}
+
+			var _ error = err
+
+			pinningErr, ok := IsPinningError(err)
+			Expect(ok).To(BeTrue())
+			Expect(pinningErr).To(BeIdenticalTo(err))
+		})
+	})
+})
@@ -21,7 +21,7 @@ type ErrorHandlerFunc func(*http.Response, error) error
 type PostHookFunc func(*http.Request, *http.Response) error
 
 type HTTPClient struct {
-	BaseURL                  *url.URL
+	BaseURL                  *url.URL // can be unix:///app.sock, see UnixSocketURL
 	Headers                  http.Header
 	Client                   *http.Client
 	PostHooks                map[int]PostHookFunc
@@ -30,6 +30,13 @@ type HTTPClient struct {
 	rateLimitChan            chan struct{}
 	rateLimitTimeout         time.Duration
 	useInvalidStatusErrorPtr bool
+	disableDecompression     bool
+	reqCompression           string
+	reqCompressionMinSize    int64
+	uploadLimiter            *BandwidthLimiter
+	downloadLimiter          *BandwidthLimiter
+	authenticator            Authenticator
+	transportOptions         *TransportOptions
 }
 
 func New() (httpClient *HTTPClient) {
@@ -71,20 +78,56 @@ func (c *HTTPClient) UseInvalidStatusErrorPtr() {
 	c.useInvalidStatusErrorPtr = true
 }
 
+func (c *HTTPClient) DisableDecompression() {
+	c.disableDecompression = true
+}
+
+func (c *HTTPClient) SetAuthenticator(authenticator Authenticator) {
+	c.authenticator = authenticator
+}
+
+// SetCredentials authenticates requests with credentials. It replaces the
+// authenticator.
+func (c *HTTPClient) SetCredentials(credentials Credentials) {
+	if authenticator, ok := credentials.(Authenticator); ok {
+		c.authenticator = authenticator
+	} else {
+		c.authenticator = NewCredentialSet(CredentialsFailover, credentials)
+	}
+}
+
+// SetBandwidthLimiters limits all request and response bodies of the client.
+// The same limiters can be shared by multiple clients. nil means no limit.
+func (c *HTTPClient) SetBandwidthLimiters(upload *BandwidthLimiter, download *BandwidthLimiter) {
+	c.uploadLimiter = upload
+	c.downloadLimiter = download
+}
+
+func (c *HTTPClient) SetReqCompression(encoding string, minSize int64) {
+	c.reqCompression = encoding
+	c.reqCompressionMinSize = minSize
+}
+
 func (c *HTTPClient) buildURL(req *RequestData) *url.URL {
 	bu := c.BaseURL
 
+	scheme, host, basePath := bu.Scheme, bu.Host, bu.Path
+
+	if socketPath, socketBasePath, ok := unixSocketPath(bu); ok {
+		scheme, host, basePath = "http", unixSocketHost(socketPath), socketBasePath
+	}
+
 	rpath := req.Path
 
-	if strings.HasSuffix(bu.Path, "/") && strings.HasPrefix(rpath, "/") {
+	if strings.HasSuffix(basePath, "/") && strings.HasPrefix(rpath, "/") {
 		rpath = rpath[1:]
 	}
 
-	opaque := EscapePath(bu.Path + rpath)
+	opaque := EscapePath(basePath + rpath)
 
 	u := &url.URL{
-		Scheme: bu.Scheme,
-		Host:   bu.Host,
+		Scheme: scheme,
+		Host:   host,
 		Opaque: opaque,
 	}
 
@@ -283,6 +326,20 @@ func (c *HTTPClient) runPostHook(req *http.Request, response *http.Response) (er
 	return err
 }
 
+func (c *HTTPClient) do(req *RequestData, r *http.Request) (response *http.Response, err error) {
+	if req.IgnoreRedirects {
+		transport := c.Client.Transport
+
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+
+		return transport.RoundTrip(r)
+	}
+
+	return c.Client.Do(r)
+}
+
 func (c *HTTPClient) Request(req *RequestData) (response *http.Response, err error) {
 	err = c.marshalRequest(req)
 
@@ -290,25 +347,181 @@ func (c *HTTPClient) Request(req *RequestData) (response *http.Response, err err
 		return nil, err
 	}
 
-	r, err := http.NewRequest(req.Method, req.FullURL, req.ReqReader)
+	body := req.ReqReader
+	reqContentLength := req.ReqContentLength
+	replayable, isReplayable := req.ReqReader.(ReplayableReader)
+
+	if isReplayable {
+		if body, err = replayable.Reopen(); err != nil {
+			return nil, err
+		}
+
+		if reqContentLength == 0 && replayable.Size() > 0 {
+			reqContentLength = replayable.Size()
+		}
+	}
+
+	// c.do closes the request body, it is closed here if the request is not
+	// sent, e.g. on RateLimitTimeoutError
+	unsentBody, _ := body.(io.Closer)
+
+	defer func() {
+		if unsentBody != nil {
+			unsentBody.Close()
+		}
+	}()
+
+	body, contentLength, contentEncoding, err := c.compressRequest(req, body, reqContentLength)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequest(req.Method, req.FullURL, body)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if isReplayable {
+		r.GetBody = func() (io.ReadCloser, error) {
+			rc, err := replayable.Reopen()
+
+			if err != nil {
+				return nil, err
+			}
+
+			body, _, _, err := c.compressRequest(req, rc, reqContentLength)
+
+			if err != nil {
+				rc.Close()
+				return nil, err
+			}
+
+			if rc, ok := body.(io.ReadCloser); ok {
+				return rc, nil
+			}
+
+			return io.NopCloser(body), nil
+		}
+	}
+
+	r.ContentLength = contentLength
+
+	if req.ReqDigest != "" && r.Body != nil && r.Body != http.NoBody {
+		if err = digestRequest(req.ReqDigest, r); err != nil {
+			return nil, err
+		}
+	}
+
 	if req.Context != nil {
 		r = r.WithContext(req.Context)
 	}
 
-	r.ContentLength = req.ReqContentLength
+	var watchdog *stallWatchdog
+
+	if req.IdleTimeout > 0 || req.MinThroughput > 0 {
+		watchdog = newStallWatchdog(r.Context(), req.IdleTimeout, req.MinThroughput, req.MinThroughputWindow)
+
+		defer func() {
+			if err != nil {
+				// a returned response body stops the watchdog when it is closed
+				if response == nil {
+					watchdog.stop()
+				}
+
+				err = watchdog.wrapErr(err)
+			}
+		}()
+
+		r = r.WithContext(watchdog.ctx)
+
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = watchdog.reader(r.Body, false)
+
+			if getBody := r.GetBody; getBody != nil {
+				r.GetBody = func() (io.ReadCloser, error) {
+					body, err := getBody()
+
+					if err != nil {
+						return nil, err
+					}
+
+					return watchdog.reader(body, false), nil
+				}
+			}
+		}
+	}
+
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = newLimitedReader(r.Context(), r.Body, req.UploadLimiter, c.uploadLimiter)
+
+		if getBody := r.GetBody; getBody != nil {
+			r.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+
+				if err != nil {
+					return nil, err
+				}
+
+				return newLimitedReader(r.Context(), body, req.UploadLimiter, c.uploadLimiter), nil
+			}
+		}
+	}
+
+	if req.ReqProgress != nil && r.Body != nil && r.Body != http.NoBody {
+		total := contentLength
+
+		if total <= 0 {
+			total, _ = inMemoryLen(body)
+		}
+
+		r.Body = newProgressReader(r.Body, total, req.ProgressInterval, req.ReqProgress)
+
+		if getBody := r.GetBody; getBody != nil {
+			r.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+
+				if err != nil {
+					return nil, err
+				}
+
+				return newProgressReader(body, total, req.ProgressInterval, req.ReqProgress), nil
+			}
+		}
+	}
 
 	if req.FullURL == "" {
 		r.URL = c.buildURL(req)
 		r.Host = r.URL.Host
+
+		if isUnixSocketHost(r.Host) {
+			r.Host = UnixSocketHostHeader
+		}
 	}
 
 	c.setHeaders(req, r)
 
+	if req.ExpectContinue && r.Body != nil && r.Body != http.NoBody {
+		r.Header.Set("Expect", "100-continue")
+	}
+
+	if contentEncoding != "" {
+		r.Header.Set("Content-Encoding", contentEncoding)
+
+		if contentLength > 0 {
+			r.Header.Set("Content-Length", fmt.Sprintf("%d", contentLength))
+		} else {
+			r.Header.Del("Content-Length")
+		}
+	}
+
+	decompress := c.shouldDecompress(req, r)
+
+	if decompress {
+		r.Header.Set("Accept-Encoding", AcceptEncoding)
+	}
+
 	if c.rateLimited {
 		if c.rateLimitTimeout > 0 {
 			select {
@@ -327,6 +540,18 @@ func (c *HTTPClient) Request(req *RequestData) (response *http.Response, err err
 		}
 	}
 
+	authenticator := req.Authenticator
+
+	if authenticator == nil {
+		authenticator = c.authenticator
+	}
+
+	if authenticator != nil {
+		if err = authenticator.Authenticate(r); err != nil {
+			return nil, err
+		}
+	}
+
 	isTraceEnabled := os.Getenv("HTTPCLIENT_TRACE") != ""
 
 	if isTraceEnabled {
@@ -334,16 +559,44 @@ func (c *HTTPClient) Request(req *RequestData) (response *http.Response, err err
 		fmt.Println(string(requestBytes))
 	}
 
-	if req.IgnoreRedirects {
-		transport := c.Client.Transport
+	unsentBody = nil
 
-		if transport == nil {
-			transport = http.DefaultTransport
+	response, err = c.do(req, r)
+
+	for attempt := 1; err == nil && authenticator != nil && isAuthChallenge(authenticator, response.StatusCode); attempt++ {
+		var retry bool
+
+		if retry, err = authenticator.Challenge(r, response, attempt); err != nil {
+			response.Body.Close()
+			return nil, err
 		}
 
-		response, err = transport.RoundTrip(r)
-	} else {
-		response, err = c.Client.Do(r)
+		hasBody := r.Body != nil && r.Body != http.NoBody
+
+		if !retry || (hasBody && r.GetBody == nil) {
+			break
+		}
+
+		io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64*1024))
+		response.Body.Close()
+
+		r = r.Clone(r.Context())
+
+		if hasBody {
+			if r.Body, err = r.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = authenticator.Authenticate(r); err != nil {
+			if hasBody {
+				r.Body.Close()
+			}
+
+			return nil, err
+		}
+
+		response, err = c.do(req, r)
 	}
 
 	if err != nil {
@@ -356,12 +609,44 @@ func (c *HTTPClient) Request(req *RequestData) (response *http.Response, err err
 			default:
 			}
 		}
+		if watchdog != nil {
+			err = watchdog.wrapErr(err)
+		}
 		if c.errorHandler != nil {
 			err = c.errorHandler(response, err)
 		}
 		return nil, err
 	}
 
+	if req.VerifySignature != nil {
+		if err = req.VerifySignature.VerifyResponse(response); err != nil {
+			response.Body.Close()
+			return nil, err
+		}
+	}
+
+	if watchdog != nil {
+		response.Body = watchdog.reader(response.Body, true)
+	}
+
+	if req.VerifyDigest {
+		response.Body = newVerifyingReader(response)
+	}
+
+	response.Body = newLimitedReader(r.Context(), response.Body, req.DownloadLimiter, c.downloadLimiter)
+
+	if req.RespProgress != nil {
+		response.Body = newProgressReader(response.Body, response.ContentLength, req.ProgressInterval, req.RespProgress)
+	}
+
+	if req.beforeDecompress != nil {
+		req.beforeDecompress(response)
+	}
+
+	if decompress {
+		decompressResponse(response)
+	}
+
 	if isTraceEnabled {
 		responseBytes, _ := httputil.DumpResponse(response, true)
 		fmt.Println(string(responseBytes))
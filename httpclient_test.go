@@ -8,7 +8,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -652,6 +655,66 @@ var _ = Describe("HTTPClient", func() {
 		})
 	})
 
+	Describe("ExpectContinue", func() {
+		BeforeEach(func() {
+			client.Client = HttpClient
+		})
+
+		It("should send body after 100 Continue", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Expect")).To(Equal("100-continue"))
+				body, _ := ioutil.ReadAll(r.Body)
+				Expect(body).To(Equal([]byte("body")))
+				fmt.Fprintln(w, "ok")
+			}
+
+			_, err := client.Request(&RequestData{
+				Method:         "PUT",
+				Path:           "/",
+				ReqReader:      bytes.NewReader([]byte("body")),
+				ExpectContinue: true,
+				ExpectedStatus: []int{200},
+				RespConsume:    true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should not send body if server rejects the request", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(403)
+				fmt.Fprint(w, "forbidden")
+			}
+
+			reader := bytes.NewReader([]byte("body"))
+
+			_, err := client.Request(&RequestData{
+				Method:         "PUT",
+				Path:           "/",
+				ReqReader:      reader,
+				ExpectContinue: true,
+				ExpectedStatus: []int{200},
+				RespConsume:    true,
+			})
+			Expect(IsInvalidStatusCode(err, 403)).To(BeTrue())
+			Expect(err.(InvalidStatusError).Content).To(Equal("forbidden"))
+			Expect(reader.Len()).To(Equal(4))
+		})
+
+		It("should not set Expect header without body", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Expect")).To(BeEmpty())
+				fmt.Fprintln(w, "ok")
+			}
+
+			_, err := client.Request(&RequestData{
+				Method:         "GET",
+				Path:           "/",
+				ExpectContinue: true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
 	Describe("RespValue", func() {
 		It("should unmarshal JSON response with EncodingJSON", func() {
 			handler = func(w http.ResponseWriter, r *http.Request) {
@@ -992,4 +1055,180 @@ var _ = Describe("HTTPClient", func() {
 		})
 	})
 
+	Describe("UploadMultipartForm", func() {
+		It("should upload ordered fields and multiple files", func() {
+			dir := GinkgoT().TempDir()
+			path := filepath.Join(dir, "path.txt")
+			Expect(os.WriteFile(path, []byte("from path"), 0644)).To(Succeed())
+
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				reader, err := r.MultipartReader()
+				Expect(err).NotTo(HaveOccurred())
+
+				expected := []struct {
+					formName    string
+					fileName    string
+					contentType string
+					header      string
+					body        string
+				}{
+					{"key", "", "", "", "uploads/file"},
+					{"policy", "", "", "", "policy"},
+					{"acl", "", "", "", "private"},
+					{"file1", "reader.txt", "text/plain", "", "from reader"},
+					{"file2", "bytes.bin", "application/octet-stream", "value", "from bytes"},
+					{"file3", "path.txt", "application/octet-stream", "", "from path"},
+				}
+
+				for _, e := range expected {
+					p, err := reader.NextPart()
+					Expect(err).NotTo(HaveOccurred())
+					body, err := ioutil.ReadAll(p)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(p.FormName()).To(Equal(e.formName))
+					Expect(p.FileName()).To(Equal(e.fileName))
+					Expect(p.Header.Get("Content-Type")).To(Equal(e.contentType))
+					Expect(p.Header.Get("X-Part")).To(Equal(e.header))
+					Expect(string(body)).To(Equal(e.body))
+				}
+
+				_, err = reader.NextPart()
+				Expect(err).To(Equal(io.EOF))
+
+				fmt.Fprintln(w, "ok")
+			}
+
+			form := NewMultipartForm()
+			form.AddField("key", "uploads/file")
+			form.AddField("policy", "policy")
+			form.AddField("acl", "private")
+			form.AddFile("file1", "reader.txt", strings.NewReader("from reader")).ContentType = "text/plain"
+			form.AddFileBytes("file2", "bytes.bin", []byte("from bytes")).Header = textproto.MIMEHeader{"X-Part": {"value"}}
+			_, err := form.AddFileFromPath("file3", path)
+			Expect(err).NotTo(HaveOccurred())
+
+			req := &RequestData{
+				Method: "POST",
+				Path:   "/",
+			}
+
+			err = req.UploadMultipartForm(form)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = client.Request(req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should use form boundary", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Content-Type")).To(Equal("multipart/form-data; boundary=boundary"))
+				fmt.Fprintln(w, "ok")
+			}
+
+			form := NewMultipartForm()
+			form.Boundary = "boundary"
+			form.AddField("foo", "bar")
+
+			req := &RequestData{
+				Method: "POST",
+				Path:   "/",
+			}
+
+			err := req.UploadMultipartForm(form)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = client.Request(req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should set content length if all part sizes are known", func() {
+			dir := GinkgoT().TempDir()
+			path := filepath.Join(dir, "file.txt")
+			Expect(os.WriteFile(path, []byte("from file"), 0644)).To(Succeed())
+
+			f, err := os.Open(path)
+			Expect(err).NotTo(HaveOccurred())
+			defer f.Close()
+
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.TransferEncoding).To(BeEmpty())
+				body, err := ioutil.ReadAll(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(r.ContentLength).To(Equal(int64(len(body))))
+				fmt.Fprintln(w, "ok")
+			}
+
+			form := NewMultipartForm()
+			form.AddField("foo", "bar")
+			form.AddFileBytes("bytes", "bytes.txt", []byte("from bytes"))
+			form.AddFile("file", "file.txt", f)
+			form.AddFile("sized", "sized.txt", &onlyReader{strings.NewReader("sized")}).Size = 5
+
+			req := &RequestData{
+				Method: "POST",
+				Path:   "/",
+			}
+
+			err = req.UploadMultipartForm(form)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(req.ReqContentLength).To(BeNumerically(">", 0))
+			Expect(req.Headers.Get("Content-Length")).To(Equal(fmt.Sprintf("%d", req.ReqContentLength)))
+
+			_, err = client.Request(req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should stream without content length if a part size is unknown", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.TransferEncoding).To(Equal([]string{"chunked"}))
+				fmt.Fprintln(w, "ok")
+			}
+
+			form := NewMultipartForm()
+			form.AddFile("file", "file.txt", &onlyReader{strings.NewReader("unknown")})
+
+			req := &RequestData{
+				Method: "POST",
+				Path:   "/",
+			}
+
+			err := req.UploadMultipartForm(form)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(req.ReqContentLength).To(Equal(int64(0)))
+
+			_, err = client.Request(req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should fail if part size does not match", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				ioutil.ReadAll(r.Body)
+				fmt.Fprintln(w, "ok")
+			}
+
+			form := NewMultipartForm()
+			form.AddFile("file", "file.txt", &onlyReader{strings.NewReader("short")}).Size = 10
+
+			req := &RequestData{
+				Method: "POST",
+				Path:   "/",
+			}
+
+			err := req.UploadMultipartForm(form)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = client.Request(req)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("size mismatch"))
+		})
+
+		It("should not add missing file", func() {
+			form := NewMultipartForm()
+
+			_, err := form.AddFileFromPath("file", "/nonexistent/file.txt")
+			Expect(err).To(HaveOccurred())
+			Expect(form.Parts()).To(BeEmpty())
+		})
+	})
+
 })
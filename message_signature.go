@@ -0,0 +1,631 @@
+package httpclient
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHMACSHA256      = "hmac-sha256"
+	SignatureEd25519         = "ed25519"
+	SignatureECDSAP256SHA256 = "ecdsa-p256-sha256"
+	SignatureRSAPSSSHA512    = "rsa-pss-sha512"
+	SignatureRSAV15SHA256    = "rsa-v1_5-sha256"
+)
+
+const DefaultSignatureLabel = "sig1"
+
+// MessageSigner signs requests with HTTP Message Signatures (RFC 9421), as an
+// Authenticator or with Sign, and responses with SignResponse.
+type MessageSigner struct {
+	Label     string // defaults to DefaultSignatureLabel
+	KeyID     string
+	Algorithm string // defaults to the algorithm of Key
+
+	// Key is []byte for hmac-sha256, ed25519.PrivateKey, *ecdsa.PrivateKey
+	// (P-256) or *rsa.PrivateKey (rsa-pss-sha512 unless Algorithm is
+	// rsa-v1_5-sha256).
+	Key interface{}
+
+	// Components are the covered components, e.g. `@method`, `content-type`
+	// or `@query-param;name="id"`. Defaults to @method, @target-uri and
+	// content-digest if the request has it.
+	Components []string
+
+	Expires time.Duration // adds the expires parameter
+	Nonce   func() string
+	Tag     string
+	Now     func() time.Time // defaults to time.Now
+}
+
+func (s *MessageSigner) Authenticate(req *http.Request) error {
+	return s.Sign(req)
+}
+
+func (s *MessageSigner) Challenge(req *http.Request, res *http.Response, attempt int) (retry bool, err error) {
+	return false, nil
+}
+
+// Sign sets the Signature-Input and Signature headers.
+func (s *MessageSigner) Sign(req *http.Request) error {
+	return s.sign(req, nil, req.Header)
+}
+
+// SignResponse sets the Signature-Input and Signature headers of res.
+// res.Request is used for components with the req parameter.
+func (s *MessageSigner) SignResponse(res *http.Response) error {
+	return s.sign(res.Request, res, res.Header)
+}
+
+func (s *MessageSigner) sign(req *http.Request, res *http.Response, header http.Header) (err error) {
+	algorithm := s.Algorithm
+
+	if algorithm == "" {
+		if algorithm, err = signatureKeyAlgorithm(s.Key); err != nil {
+			return err
+		}
+	}
+
+	names := s.Components
+
+	if names == nil {
+		names = []string{"@method", "@target-uri"}
+
+		if header.Get("Content-Digest") != "" {
+			names = append(names, "content-digest")
+		}
+	}
+
+	components := make([]sfItem, len(names))
+
+	for i, name := range names {
+		if components[i], err = parseSignatureComponent(name); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+
+	if s.Now != nil {
+		now = s.Now()
+	}
+
+	params := []sfParam{{"created", now.Unix()}}
+
+	if s.Expires > 0 {
+		params = append(params, sfParam{"expires", now.Add(s.Expires).Unix()})
+	}
+
+	if s.Nonce != nil {
+		params = append(params, sfParam{"nonce", s.Nonce()})
+	}
+
+	if s.KeyID != "" {
+		params = append(params, sfParam{"keyid", s.KeyID})
+	}
+
+	if s.Tag != "" {
+		params = append(params, sfParam{"tag", s.Tag})
+	}
+
+	signatureParams := serializeSFInnerList(components, params)
+
+	base, err := signatureBase(req, res, components, signatureParams)
+
+	if err != nil {
+		return err
+	}
+
+	signature, err := signMessage(algorithm, s.Key, base)
+
+	if err != nil {
+		return err
+	}
+
+	label := s.Label
+
+	if label == "" {
+		label = DefaultSignatureLabel
+	}
+
+	header.Set("Signature-Input", label+"="+signatureParams)
+	header.Set("Signature", label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+
+	return nil
+}
+
+// MessageVerifier verifies HTTP Message Signatures (RFC 9421) of responses
+// (or requests).
+type MessageVerifier struct {
+	Label string // defaults to the first signature
+
+	// Key returns the key for keyid. key is []byte for hmac-sha256 or a public
+	// key. algorithm can be empty if it is implied by the key.
+	Key func(keyID string) (key interface{}, algorithm string, err error)
+
+	// RequiredComponents must be covered by the signature, e.g. @status or
+	// content-digest.
+	RequiredComponents []string
+
+	MaxAge time.Duration    // max age of the created parameter, 0 means no limit
+	Now    func() time.Time // defaults to time.Now
+}
+
+func (v *MessageVerifier) VerifyRequest(req *http.Request) error {
+	return v.verify(req, nil, req.Header)
+}
+
+// VerifyResponse verifies the response signature. res.Request is used for
+// components with the req parameter.
+func (v *MessageVerifier) VerifyResponse(res *http.Response) error {
+	return v.verify(res.Request, res, res.Header)
+}
+
+func (v *MessageVerifier) verify(req *http.Request, res *http.Response, header http.Header) (err error) {
+	label := v.Label
+
+	fail := func(format string, a ...interface{}) error {
+		return SignatureError{
+			Label:  label,
+			Reason: fmt.Sprintf(format, a...),
+		}
+	}
+
+	inputs, err := parseSFDictionary(strings.Join(header.Values("Signature-Input"), ", "))
+
+	if err != nil {
+		return fail("invalid Signature-Input: %s", err)
+	}
+
+	signatures, err := parseSFDictionary(strings.Join(header.Values("Signature"), ", "))
+
+	if err != nil {
+		return fail("invalid Signature: %s", err)
+	}
+
+	var input *sfMember
+
+	for i := range inputs {
+		if label == "" || inputs[i].key == label {
+			input = &inputs[i]
+			break
+		}
+	}
+
+	if input == nil {
+		return fail("signature not found")
+	}
+
+	label = input.key
+
+	if !input.isList {
+		return fail("Signature-Input is not an inner list")
+	}
+
+	var signature []byte
+
+	for _, member := range signatures {
+		if member.key == label && !member.isList {
+			signature, _ = member.item.value.([]byte)
+		}
+	}
+
+	if signature == nil {
+		return fail("Signature not found")
+	}
+
+	covered := map[string]bool{}
+
+	for _, component := range input.list {
+		if _, ok := component.value.(string); !ok {
+			return fail("invalid component")
+		}
+
+		covered[serializeSFItem(component)] = true
+	}
+
+	for _, name := range v.RequiredComponents {
+		component, err := parseSignatureComponent(name)
+
+		if err != nil {
+			return err
+		}
+
+		if !covered[serializeSFItem(component)] {
+			return fail("component %s not covered", name)
+		}
+	}
+
+	var keyID, algorithm string
+	var created, expires int64
+
+	for _, param := range input.item.params {
+		switch param.key {
+		case "keyid":
+			keyID, _ = param.value.(string)
+		case "alg":
+			algorithm, _ = param.value.(string)
+		case "created":
+			created, _ = param.value.(int64)
+		case "expires":
+			expires, _ = param.value.(int64)
+		}
+	}
+
+	now := time.Now()
+
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	if expires != 0 && now.Unix() > expires {
+		return fail("signature expired")
+	}
+
+	if v.MaxAge > 0 && (created == 0 || now.Sub(time.Unix(created, 0)) > v.MaxAge) {
+		return fail("signature too old")
+	}
+
+	if v.Key == nil {
+		return fail("no key")
+	}
+
+	key, keyAlgorithm, err := v.Key(keyID)
+
+	if err != nil {
+		return err
+	}
+
+	if signer, ok := key.(crypto.Signer); ok {
+		key = signer.Public()
+	}
+
+	if keyAlgorithm == "" {
+		if keyAlgorithm, err = signatureKeyAlgorithm(key); err != nil {
+			return err
+		}
+	}
+
+	if algorithm != "" && algorithm != keyAlgorithm {
+		return fail("algorithm %s does not match the key", algorithm)
+	}
+
+	base, err := signatureBase(req, res, input.list, input.raw)
+
+	if err != nil {
+		return fail("%s", err)
+	}
+
+	if !verifyMessage(keyAlgorithm, key, base, signature) {
+		return fail("invalid signature")
+	}
+
+	return nil
+}
+
+func signatureKeyAlgorithm(key interface{}) (algorithm string, err error) {
+	switch key := key.(type) {
+	case []byte:
+		return SignatureHMACSHA256, nil
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return SignatureEd25519, nil
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("HTTPClient: unsupported ECDSA curve: %s", key.Curve.Params().Name)
+		}
+		return SignatureECDSAP256SHA256, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("HTTPClient: unsupported ECDSA curve: %s", key.Curve.Params().Name)
+		}
+		return SignatureECDSAP256SHA256, nil
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return SignatureRSAPSSSHA512, nil
+	}
+
+	return "", fmt.Errorf("HTTPClient: unsupported signature key type %T", key)
+}
+
+func signMessage(algorithm string, key interface{}, base []byte) (signature []byte, err error) {
+	switch algorithm {
+	case SignatureHMACSHA256:
+		if key, ok := key.([]byte); ok {
+			h := hmac.New(sha256.New, key)
+			h.Write(base)
+			return h.Sum(nil), nil
+		}
+
+	case SignatureEd25519:
+		if key, ok := key.(ed25519.PrivateKey); ok {
+			return ed25519.Sign(key, base), nil
+		}
+
+	case SignatureECDSAP256SHA256:
+		if key, ok := key.(*ecdsa.PrivateKey); ok {
+			sum := sha256.Sum256(base)
+
+			r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+
+			if err != nil {
+				return nil, err
+			}
+
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+
+			return signature, nil
+		}
+
+	case SignatureRSAPSSSHA512:
+		if key, ok := key.(*rsa.PrivateKey); ok {
+			sum := sha512.Sum512(base)
+			return rsa.SignPSS(rand.Reader, key, crypto.SHA512, sum[:], &rsa.PSSOptions{SaltLength: 64})
+		}
+
+	case SignatureRSAV15SHA256:
+		if key, ok := key.(*rsa.PrivateKey); ok {
+			sum := sha256.Sum256(base)
+			return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		}
+
+	default:
+		return nil, fmt.Errorf("HTTPClient: unsupported signature algorithm: %s", algorithm)
+	}
+
+	return nil, fmt.Errorf("HTTPClient: invalid key type %T for signature algorithm %s", key, algorithm)
+}
+
+func verifyMessage(algorithm string, key interface{}, base []byte, signature []byte) bool {
+	switch algorithm {
+	case SignatureHMACSHA256:
+		if key, ok := key.([]byte); ok {
+			h := hmac.New(sha256.New, key)
+			h.Write(base)
+			return hmac.Equal(h.Sum(nil), signature)
+		}
+
+	case SignatureEd25519:
+		if key, ok := key.(ed25519.PublicKey); ok {
+			return ed25519.Verify(key, base, signature)
+		}
+
+	case SignatureECDSAP256SHA256:
+		if key, ok := key.(*ecdsa.PublicKey); ok && len(signature) == 64 {
+			sum := sha256.Sum256(base)
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			return ecdsa.Verify(key, sum[:], r, s)
+		}
+
+	case SignatureRSAPSSSHA512:
+		if key, ok := key.(*rsa.PublicKey); ok {
+			sum := sha512.Sum512(base)
+			return rsa.VerifyPSS(key, crypto.SHA512, sum[:], signature, &rsa.PSSOptions{SaltLength: 64}) == nil
+		}
+
+	case SignatureRSAV15SHA256:
+		if key, ok := key.(*rsa.PublicKey); ok {
+			sum := sha256.Sum256(base)
+			return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature) == nil
+		}
+	}
+
+	return false
+}
+
+// parseSignatureComponent parses a component identifier. The name can be
+// quoted or not, e.g. `"@query-param";name="id"` or `@query-param;name="id"`.
+func parseSignatureComponent(s string) (component sfItem, err error) {
+	if !strings.HasPrefix(s, `"`) {
+		name, params, _ := strings.Cut(s, ";")
+
+		s = strconv.Quote(strings.ToLower(name))
+
+		if params != "" {
+			s += ";" + params
+		}
+	}
+
+	p := &sfParser{s: s}
+
+	if component, err = p.parseItem(); err != nil || p.s != "" {
+		return sfItem{}, fmt.Errorf("HTTPClient: invalid signature component: %s", s)
+	}
+
+	if _, ok := component.value.(string); !ok {
+		return sfItem{}, fmt.Errorf("HTTPClient: invalid signature component: %s", s)
+	}
+
+	return component, nil
+}
+
+// signatureBase creates the signature base (RFC 9421 section 2.5). res is nil
+// for request signatures.
+func signatureBase(req *http.Request, res *http.Response, components []sfItem, signatureParams string) (base []byte, err error) {
+	var b strings.Builder
+
+	seen := map[string]bool{}
+
+	for _, component := range components {
+		id := serializeSFItem(component)
+
+		if seen[id] {
+			return nil, fmt.Errorf("HTTPClient: duplicate signature component: %s", id)
+		}
+
+		seen[id] = true
+
+		value, err := signatureComponentValue(req, res, component)
+
+		if err != nil {
+			return nil, err
+		}
+
+		b.WriteString(id)
+		b.WriteString(": ")
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+
+	b.WriteString(`"@signature-params": `)
+	b.WriteString(signatureParams)
+
+	return []byte(b.String()), nil
+}
+
+func signatureComponentValue(req *http.Request, res *http.Response, component sfItem) (value string, err error) {
+	name := component.value.(string)
+
+	var fromReq bool
+	var paramName string
+	hasName := false
+
+	for _, param := range component.params {
+		switch param.key {
+		case "req":
+			fromReq = param.value == true
+		case "name":
+			paramName, hasName = param.value.(string)
+		default:
+			return "", fmt.Errorf("HTTPClient: unsupported signature component parameter: %s", param.key)
+		}
+	}
+
+	if fromReq && res == nil {
+		return "", fmt.Errorf("HTTPClient: req parameter used in a request signature: %s", name)
+	}
+
+	// components of the request are used if the message is a request or the
+	// req parameter is set
+	useReq := res == nil || fromReq
+
+	if !strings.HasPrefix(name, "@") {
+		var header http.Header
+
+		if !useReq {
+			header = res.Header
+		} else if req != nil {
+			header = req.Header
+		} else {
+			return "", fmt.Errorf("HTTPClient: missing request for signature component: %s", name)
+		}
+
+		values := header.Values(name)
+
+		if len(values) == 0 {
+			return "", fmt.Errorf("HTTPClient: signature component %s not found", name)
+		}
+
+		for i, v := range values {
+			values[i] = strings.TrimSpace(v)
+		}
+
+		return strings.Join(values, ", "), nil
+	}
+
+	if name == "@status" {
+		if useReq {
+			return "", fmt.Errorf("HTTPClient: @status used for a request")
+		}
+
+		return fmt.Sprintf("%03d", res.StatusCode), nil
+	}
+
+	if req == nil {
+		return "", fmt.Errorf("HTTPClient: missing request for signature component: %s", name)
+	}
+
+	u, err := parseRequestURL(req.URL)
+
+	if err != nil {
+		return "", err
+	}
+
+	requestURI := req.URL.RequestURI()
+
+	authority := req.Host
+
+	if authority == "" {
+		authority = u.Host
+	}
+
+	authority = strings.ToLower(authority)
+
+	scheme := strings.ToLower(u.Scheme)
+
+	if (scheme == "http" && strings.HasSuffix(authority, ":80")) || (scheme == "https" && strings.HasSuffix(authority, ":443")) {
+		authority = authority[:strings.LastIndex(authority, ":")]
+	}
+
+	path, _, _ := strings.Cut(requestURI, "?")
+
+	if path == "" {
+		path = "/"
+	}
+
+	switch name {
+	case "@method":
+		return req.Method, nil
+
+	case "@target-uri":
+		return scheme + "://" + authority + requestURI, nil
+
+	case "@authority":
+		return authority, nil
+
+	case "@scheme":
+		return scheme, nil
+
+	case "@request-target":
+		return requestURI, nil
+
+	case "@path":
+		return path, nil
+
+	case "@query":
+		return "?" + u.RawQuery, nil
+
+	case "@query-param":
+		if !hasName {
+			return "", fmt.Errorf("HTTPClient: @query-param without name")
+		}
+
+		query, err := url.ParseQuery(u.RawQuery)
+
+		if err != nil {
+			return "", err
+		}
+
+		paramName, err = url.QueryUnescape(paramName)
+
+		if err != nil {
+			return "", err
+		}
+
+		values := query[paramName]
+
+		if len(values) != 1 {
+			return "", fmt.Errorf("HTTPClient: @query-param %s must have exactly one value", paramName)
+		}
+
+		return strings.ReplaceAll(url.QueryEscape(values[0]), "+", "%20"), nil
+	}
+
+	return "", fmt.Errorf("HTTPClient: unsupported signature component: %s", name)
+}
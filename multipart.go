@@ -0,0 +1,278 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+type MultipartPart struct {
+	FieldName   string
+	FileName    string
+	ContentType string
+	Header      textproto.MIMEHeader
+	Reader      io.Reader
+	Path        string // file is opened when the part is written
+	Size        int64  // detected from Reader or Path if 0
+}
+
+func (p *MultipartPart) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+
+	for k, vs := range p.Header {
+		h[k] = append([]string(nil), vs...)
+	}
+
+	if h.Get("Content-Disposition") == "" {
+		if p.FileName != "" {
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(p.FieldName), quoteEscaper.Replace(p.FileName)))
+		} else {
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(p.FieldName)))
+		}
+	}
+
+	if p.ContentType != "" {
+		h.Set("Content-Type", p.ContentType)
+	} else if p.FileName != "" && h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "application/octet-stream")
+	}
+
+	return h
+}
+
+func readerSize(reader io.Reader) (size int64, ok bool) {
+	if size, ok = inMemoryLen(reader); ok {
+		return size, true
+	}
+
+	if f, ok := reader.(*os.File); ok {
+		info, err := f.Stat()
+
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+	}
+
+	if seeker, ok := reader.(io.Seeker); ok {
+		cur, err := seeker.Seek(0, io.SeekCurrent)
+
+		if err != nil {
+			return 0, false
+		}
+
+		end, err := seeker.Seek(0, io.SeekEnd)
+
+		if err != nil {
+			return 0, false
+		}
+
+		if _, err = seeker.Seek(cur, io.SeekStart); err != nil {
+			return 0, false
+		}
+
+		return end - cur, true
+	}
+
+	return 0, false
+}
+
+func (p *MultipartPart) size() (size int64, ok bool) {
+	if p.Size > 0 {
+		return p.Size, true
+	}
+
+	if p.Path != "" {
+		info, err := os.Stat(p.Path)
+
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+
+		return info.Size(), true
+	}
+
+	if p.Reader == nil {
+		return 0, true
+	}
+
+	return readerSize(p.Reader)
+}
+
+func (p *MultipartPart) writeTo(w io.Writer, size int64) (err error) {
+	reader := p.Reader
+
+	if p.Path != "" {
+		f, err := os.Open(p.Path)
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		reader = f
+	}
+
+	if reader == nil {
+		return nil
+	}
+
+	n, err := io.Copy(w, reader)
+
+	if err != nil {
+		return err
+	}
+
+	if size >= 0 && n != size {
+		return fmt.Errorf("HTTPClient: multipart part %s size mismatch: expected %d, got %d", p.FieldName, size, n)
+	}
+
+	return nil
+}
+
+type MultipartForm struct {
+	Boundary string
+	parts    []*MultipartPart
+}
+
+func NewMultipartForm() *MultipartForm {
+	return &MultipartForm{}
+}
+
+func (f *MultipartForm) Parts() []*MultipartPart {
+	return f.parts
+}
+
+func (f *MultipartForm) AddPart(part *MultipartPart) *MultipartPart {
+	f.parts = append(f.parts, part)
+	return part
+}
+
+func (f *MultipartForm) AddField(name string, value string) *MultipartPart {
+	return f.AddPart(&MultipartPart{
+		FieldName: name,
+		Reader:    strings.NewReader(value),
+	})
+}
+
+func (f *MultipartForm) AddFile(fieldName string, fileName string, reader io.Reader) *MultipartPart {
+	return f.AddPart(&MultipartPart{
+		FieldName: fieldName,
+		FileName:  fileName,
+		Reader:    reader,
+	})
+}
+
+func (f *MultipartForm) AddFileBytes(fieldName string, fileName string, data []byte) *MultipartPart {
+	return f.AddPart(&MultipartPart{
+		FieldName: fieldName,
+		FileName:  fileName,
+		Reader:    bytes.NewReader(data),
+	})
+}
+
+func (f *MultipartForm) AddFileFromPath(fieldName string, path string) (part *MultipartPart, err error) {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return nil, fmt.Errorf("HTTPClient: %s is a directory", path)
+	}
+
+	return f.AddPart(&MultipartPart{
+		FieldName: fieldName,
+		FileName:  filepath.Base(path),
+		Path:      path,
+	}), nil
+}
+
+func (f *MultipartForm) newWriter(w io.Writer) (writer *multipart.Writer, err error) {
+	writer = multipart.NewWriter(w)
+
+	if f.Boundary != "" {
+		if err = writer.SetBoundary(f.Boundary); err != nil {
+			return nil, err
+		}
+	}
+
+	return writer, nil
+}
+
+// sizes returns the content size of each part, or -1 if it is unknown. If all
+// sizes are known, the exact length of the encoded form is returned as well.
+func (f *MultipartForm) sizes(boundary string) (sizes []int64, contentLength int64, ok bool) {
+	sizes = make([]int64, len(f.parts))
+	ok = true
+
+	for i, p := range f.parts {
+		size, known := p.size()
+
+		if !known {
+			sizes[i] = -1
+			ok = false
+		} else {
+			sizes[i] = size
+		}
+	}
+
+	if !ok {
+		return sizes, 0, false
+	}
+
+	cw := &countingWriter{}
+
+	writer := multipart.NewWriter(cw)
+
+	if err := writer.SetBoundary(boundary); err != nil {
+		return sizes, 0, false
+	}
+
+	for i, p := range f.parts {
+		if _, err := writer.CreatePart(p.header()); err != nil {
+			return sizes, 0, false
+		}
+
+		cw.n += sizes[i]
+	}
+
+	if err := writer.Close(); err != nil {
+		return sizes, 0, false
+	}
+
+	return sizes, cw.n, true
+}
+
+func (f *MultipartForm) writeTo(writer *multipart.Writer, sizes []int64) (err error) {
+	for i, p := range f.parts {
+		w, err := writer.CreatePart(p.header())
+
+		if err != nil {
+			return err
+		}
+
+		if err = p.writeTo(w, sizes[i]); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (n int, err error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,196 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type MultipartUploadPart struct {
+	Number int // starts with 1
+	Offset int64
+	Size   int64
+	ETag   string
+}
+
+// MultipartUploader uploads an io.ReaderAt with an initiate / upload part /
+// complete protocol, e.g. S3 multipart uploads. Protocol specific requests are
+// implemented by the callbacks. Abort is optional.
+type MultipartUploader struct {
+	Client          *HTTPClient
+	PartSize        int64
+	MaxParts        int
+	Concurrency     int
+	MaxRetries      int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	Initiate   func(ctx context.Context, c *HTTPClient) (uploadID string, err error)
+	UploadPart func(ctx context.Context, c *HTTPClient, uploadID string, part *MultipartUploadPart, body io.ReadSeeker) (etag string, err error)
+	Complete   func(ctx context.Context, c *HTTPClient, uploadID string, parts []*MultipartUploadPart) error
+	Abort      func(ctx context.Context, c *HTTPClient, uploadID string) error
+}
+
+func NewMultipartUploader(client *HTTPClient) *MultipartUploader {
+	return &MultipartUploader{
+		Client:          client,
+		PartSize:        8 * 1024 * 1024,
+		MaxParts:        10000,
+		Concurrency:     4,
+		MaxRetries:      3,
+		RetryBackoff:    1 * time.Second,
+		MaxRetryBackoff: 30 * time.Second,
+	}
+}
+
+func (u *MultipartUploader) parts(size int64) []*MultipartUploadPart {
+	partSize := u.PartSize
+
+	if partSize <= 0 {
+		partSize = size
+	}
+
+	if u.MaxParts > 0 && partSize*int64(u.MaxParts) < size {
+		partSize = (size + int64(u.MaxParts) - 1) / int64(u.MaxParts)
+	}
+
+	parts := []*MultipartUploadPart{}
+
+	for offset := int64(0); offset < size || len(parts) == 0; offset += partSize {
+		partLen := partSize
+
+		if offset+partLen > size {
+			partLen = size - offset
+		}
+
+		parts = append(parts, &MultipartUploadPart{
+			Number: len(parts) + 1,
+			Offset: offset,
+			Size:   partLen,
+		})
+	}
+
+	return parts
+}
+
+func (u *MultipartUploader) uploadPart(ctx context.Context, uploadID string, r io.ReaderAt, part *MultipartUploadPart) (err error) {
+	failures := 0
+
+	for {
+		var etag string
+
+		etag, err = u.UploadPart(ctx, u.Client, uploadID, part, io.NewSectionReader(r, part.Offset, part.Size))
+
+		if err == nil {
+			part.ETag = etag
+			return nil
+		}
+
+		if !isRetryableError(ctx, err) {
+			return err
+		}
+
+		failures++
+
+		if failures > u.MaxRetries {
+			return err
+		}
+
+		if err := sleepContext(ctx, retryBackoff(u.RetryBackoff, u.MaxRetryBackoff, failures)); err != nil {
+			return err
+		}
+	}
+}
+
+func (u *MultipartUploader) uploadParts(ctx context.Context, uploadID string, r io.ReaderAt, parts []*MultipartUploadPart) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := u.Concurrency
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	if concurrency > len(parts) {
+		concurrency = len(parts)
+	}
+
+	queue := make(chan *MultipartUploadPart)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for part := range queue {
+				if partErr := u.uploadPart(ctx, uploadID, r, part); partErr != nil {
+					once.Do(func() {
+						err = partErr
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+loop:
+	for _, part := range parts {
+		select {
+		case queue <- part:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	if err == nil {
+		// the remaining parts were not uploaded
+		err = ctx.Err()
+	}
+
+	return err
+}
+
+// Upload uploads size bytes from r. The upload is aborted if any of the parts
+// or the completion fails.
+func (u *MultipartUploader) Upload(ctx context.Context, r io.ReaderAt, size int64) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if u.Initiate == nil || u.UploadPart == nil || u.Complete == nil {
+		return fmt.Errorf("HTTPClient: multipart upload callbacks are not set")
+	}
+
+	uploadID, err := u.Initiate(ctx, u.Client)
+
+	if err != nil {
+		return err
+	}
+
+	parts := u.parts(size)
+
+	err = u.uploadParts(ctx, uploadID, r, parts)
+
+	if err == nil {
+		err = u.Complete(ctx, u.Client, uploadID, parts)
+	}
+
+	if err != nil && u.Abort != nil {
+		// abort even if the upload was canceled
+		if abortErr := u.Abort(context.WithoutCancel(ctx), u.Client, uploadID); abortErr != nil {
+			return fmt.Errorf("%w (abort failed: %v)", err, abortErr)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,226 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("MultipartUploader", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var uploader *MultipartUploader
+	var mutex sync.Mutex
+	var parts map[int][]byte
+	var failPart func(number int) bool
+	var aborted bool
+	var completed []*MultipartUploadPart
+	var active, maxActive int32
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	BeforeEach(func() {
+		parts = map[int][]byte{}
+		failPart = nil
+		aborted = false
+		completed = nil
+		active = 0
+		maxActive = 0
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+
+			switch r.Method {
+			case "POST":
+				w.Write([]byte("upload-id"))
+
+			case "PUT":
+				n := atomic.AddInt32(&active, 1)
+				defer atomic.AddInt32(&active, -1)
+
+				for {
+					m := atomic.LoadInt32(&maxActive)
+					if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+						break
+					}
+				}
+
+				time.Sleep(5 * time.Millisecond)
+
+				Expect(r.URL.Query().Get("uploadId")).To(Equal("upload-id"))
+				number, _ := strconv.Atoi(r.URL.Query().Get("partNumber"))
+				data, _ := io.ReadAll(r.Body)
+
+				mutex.Lock()
+				fail := failPart != nil && failPart(number)
+				if !fail {
+					parts[number] = data
+				}
+				mutex.Unlock()
+
+				if fail {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+
+			case "DELETE":
+				aborted = true
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+
+		uploader = NewMultipartUploader(client)
+		uploader.PartSize = 3000
+		uploader.Concurrency = 2
+		uploader.RetryBackoff = time.Millisecond
+
+		uploader.Initiate = func(ctx context.Context, c *HTTPClient) (string, error) {
+			res, err := c.Request(&RequestData{
+				Context:        ctx,
+				Method:         "POST",
+				Path:           "/file",
+				ExpectedStatus: []int{http.StatusOK},
+			})
+			if err != nil {
+				return "", err
+			}
+			defer res.Body.Close()
+			id, err := io.ReadAll(res.Body)
+			return string(id), err
+		}
+
+		uploader.UploadPart = func(ctx context.Context, c *HTTPClient, uploadID string, part *MultipartUploadPart, body io.ReadSeeker) (string, error) {
+			res, err := c.Request(&RequestData{
+				Context: ctx,
+				Method:  "PUT",
+				Path:    "/file",
+				Params: url.Values{
+					"uploadId":   {uploadID},
+					"partNumber": {strconv.Itoa(part.Number)},
+				},
+				ReqReader:        body,
+				ReqContentLength: part.Size,
+				ExpectedStatus:   []int{http.StatusOK},
+				RespConsume:      true,
+			})
+			if err != nil {
+				return "", err
+			}
+			return res.Header.Get("ETag"), nil
+		}
+
+		uploader.Complete = func(ctx context.Context, c *HTTPClient, uploadID string, p []*MultipartUploadPart) error {
+			completed = p
+			return nil
+		}
+
+		uploader.Abort = func(ctx context.Context, c *HTTPClient, uploadID string) error {
+			_, err := c.Request(&RequestData{
+				Context:        ctx,
+				Method:         "DELETE",
+				Path:           "/file",
+				Params:         url.Values{"uploadId": {uploadID}},
+				ExpectedStatus: []int{http.StatusNoContent},
+				RespConsume:    true,
+			})
+			return err
+		}
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	It("should upload parts concurrently and complete", func() {
+		err := uploader.Upload(context.Background(), bytes.NewReader(content), int64(len(content)))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(parts).To(HaveLen(4))
+		Expect(bytes.Join([][]byte{parts[1], parts[2], parts[3], parts[4]}, nil)).To(Equal(content))
+		Expect(parts[4]).To(HaveLen(1000))
+
+		Expect(completed).To(HaveLen(4))
+		for i, p := range completed {
+			Expect(p.Number).To(Equal(i + 1))
+			Expect(p.ETag).To(Equal(fmt.Sprintf(`"etag-%d"`, i+1)))
+		}
+
+		Expect(int(maxActive)).To(Equal(2))
+		Expect(aborted).To(BeFalse())
+	})
+
+	It("should retry failed parts", func() {
+		failures := 0
+
+		failPart = func(number int) bool {
+			if number == 2 && failures < 2 {
+				failures++
+				return true
+			}
+			return false
+		}
+
+		err := uploader.Upload(context.Background(), bytes.NewReader(content), int64(len(content)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parts[2]).To(Equal(content[3000:6000]))
+		Expect(completed).To(HaveLen(4))
+	})
+
+	It("should increase part size to stay within max parts", func() {
+		uploader.MaxParts = 2
+
+		err := uploader.Upload(context.Background(), bytes.NewReader(content), int64(len(content)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parts).To(HaveLen(2))
+		Expect(parts[1]).To(HaveLen(5000))
+	})
+
+	It("should abort upload on failure", func() {
+		uploader.MaxRetries = 1
+
+		failPart = func(number int) bool {
+			return number == 3
+		}
+
+		err := uploader.Upload(context.Background(), bytes.NewReader(content), int64(len(content)))
+		Expect(IsInvalidStatusCode(err, http.StatusInternalServerError)).To(BeTrue())
+		Expect(completed).To(BeNil())
+		Expect(aborted).To(BeTrue())
+	})
+
+	It("should abort upload when canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		initiate := uploader.Initiate
+		uploader.Initiate = func(ctx context.Context, c *HTTPClient) (string, error) {
+			defer cancel()
+			return initiate(ctx, c)
+		}
+
+		err := uploader.Upload(ctx, bytes.NewReader(content), int64(len(content)))
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(completed).To(BeNil())
+		Expect(aborted).To(BeTrue())
+	})
+})
@@ -0,0 +1,422 @@
+package httpclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OAuth2GrantClientCredentials = "client_credentials"
+	OAuth2GrantRefreshToken      = "refresh_token"
+	OAuth2GrantJWTBearer         = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+// DefaultOAuth2ExpiryDelta is how long before the expiry the token is
+// refreshed.
+var DefaultOAuth2ExpiryDelta = 30 * time.Second
+
+type OAuth2Config struct {
+	Client       *HTTPClient // client for token requests, defaults to New()
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	AuthInParams bool // send client credentials in the form instead of basic auth
+	ExpiryDelta  time.Duration
+}
+
+type OAuth2Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"`
+	Expiry       time.Time `json:"-"`
+}
+
+func (t *OAuth2Token) valid(delta time.Duration) bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry))
+}
+
+type oauth2Refresh struct {
+	done  chan struct{}
+	token *OAuth2Token
+	err   error
+}
+
+// OAuth2TokenSource acquires and refreshes OAuth2 tokens. As an Authenticator
+// it retries a request once with a new token if the token is rejected with
+// invalid_token.
+type OAuth2TokenSource struct {
+	config       OAuth2Config
+	grantType    string
+	assertion    func() (string, error)
+	mutex        sync.Mutex
+	token        *OAuth2Token
+	refreshToken string
+	refresh      *oauth2Refresh
+}
+
+func NewOAuth2ClientCredentials(config OAuth2Config) *OAuth2TokenSource {
+	return &OAuth2TokenSource{
+		config:    config,
+		grantType: OAuth2GrantClientCredentials,
+	}
+}
+
+func NewOAuth2RefreshToken(config OAuth2Config, refreshToken string) *OAuth2TokenSource {
+	return &OAuth2TokenSource{
+		config:       config,
+		grantType:    OAuth2GrantRefreshToken,
+		refreshToken: refreshToken,
+	}
+}
+
+// NewOAuth2JWTBearer uses the JWT bearer grant (RFC 7523). assertion is called
+// for every token request, see OAuth2JWTAssertion.
+func NewOAuth2JWTBearer(config OAuth2Config, assertion func() (string, error)) *OAuth2TokenSource {
+	return &OAuth2TokenSource{
+		config:    config,
+		grantType: OAuth2GrantJWTBearer,
+		assertion: assertion,
+	}
+}
+
+// SetToken sets the current token, e.g. one persisted from a previous run.
+func (s *OAuth2TokenSource) SetToken(token *OAuth2Token) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.token = token
+
+	if token != nil && token.RefreshToken != "" && s.grantType == OAuth2GrantRefreshToken {
+		s.refreshToken = token.RefreshToken
+	}
+}
+
+// Token returns a valid token and refreshes it if it expires within
+// ExpiryDelta. Concurrent refreshes are deduplicated.
+func (s *OAuth2TokenSource) Token(ctx context.Context) (token *OAuth2Token, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	expiryDelta := s.config.ExpiryDelta
+
+	if expiryDelta == 0 {
+		expiryDelta = DefaultOAuth2ExpiryDelta
+	}
+
+	s.mutex.Lock()
+
+	if s.token != nil && s.token.valid(expiryDelta) {
+		token = s.token
+		s.mutex.Unlock()
+		return token, nil
+	}
+
+	refresh := s.refresh
+
+	if refresh == nil {
+		refresh = &oauth2Refresh{
+			done: make(chan struct{}),
+		}
+		s.refresh = refresh
+
+		// the refresh is shared so it must not be canceled with the first
+		// caller's context
+		go s.runRefresh(context.WithoutCancel(ctx), refresh)
+	}
+
+	s.mutex.Unlock()
+
+	select {
+	case <-refresh.done:
+		return refresh.token, refresh.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *OAuth2TokenSource) runRefresh(ctx context.Context, refresh *oauth2Refresh) {
+	s.mutex.Lock()
+	refreshToken := s.refreshToken
+	s.mutex.Unlock()
+
+	token, err := s.fetch(ctx, refreshToken)
+
+	s.mutex.Lock()
+
+	if err == nil {
+		s.token = token
+
+		if token.RefreshToken != "" && s.grantType == OAuth2GrantRefreshToken {
+			s.refreshToken = token.RefreshToken
+		}
+	}
+
+	s.refresh = nil
+
+	s.mutex.Unlock()
+
+	refresh.token = token
+	refresh.err = err
+
+	close(refresh.done)
+}
+
+func (s *OAuth2TokenSource) fetch(ctx context.Context, refreshToken string) (token *OAuth2Token, err error) {
+	params := url.Values{}
+	params.Set("grant_type", s.grantType)
+
+	switch s.grantType {
+	case OAuth2GrantRefreshToken:
+		params.Set("refresh_token", refreshToken)
+
+	case OAuth2GrantJWTBearer:
+		assertion, err := s.assertion()
+
+		if err != nil {
+			return nil, err
+		}
+
+		params.Set("assertion", assertion)
+	}
+
+	if len(s.config.Scopes) > 0 {
+		params.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+
+	headers := make(http.Header)
+
+	if s.config.AuthInParams {
+		params.Set("client_id", s.config.ClientID)
+
+		if s.config.ClientSecret != "" {
+			params.Set("client_secret", s.config.ClientSecret)
+		}
+	} else if s.config.ClientID != "" {
+		// RFC 6749 2.3.1 requires form encoding of the credentials
+		credentials := url.QueryEscape(s.config.ClientID) + ":" + url.QueryEscape(s.config.ClientSecret)
+		headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	client := s.config.Client
+
+	if client == nil {
+		client = New()
+	} else if client.authenticator != nil {
+		// the token request must not be authenticated with this token source
+		noAuth := *client
+		noAuth.authenticator = nil
+		client = &noAuth
+	}
+
+	token = &OAuth2Token{}
+
+	_, err = client.Request(&RequestData{
+		Context:        ctx,
+		Method:         "POST",
+		FullURL:        s.config.TokenURL,
+		Headers:        headers,
+		ReqEncoding:    EncodingForm,
+		ReqValue:       params,
+		ReqCompression: CompressionNone,
+		ExpectedStatus: []int{http.StatusOK},
+		RespEncoding:   EncodingJSON,
+		RespValue:      token,
+	})
+
+	if ise, ok := IsInvalidStatusError(err); ok {
+		oauth2Err := OAuth2Error{
+			StatusCode: ise.Got,
+		}
+
+		if json.Unmarshal([]byte(ise.Content), &oauth2Err) == nil && oauth2Err.Code != "" {
+			return nil, oauth2Err
+		}
+
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("HTTPClient: OAuth2 token response without access_token")
+	}
+
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+// Invalidate forces a refresh if accessToken is still the current token.
+func (s *OAuth2TokenSource) Invalidate(accessToken string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.token != nil && s.token.AccessToken == accessToken {
+		s.token = nil
+	}
+}
+
+func (s *OAuth2TokenSource) Authenticate(req *http.Request) error {
+	token, err := s.Token(req.Context())
+
+	if err != nil {
+		return err
+	}
+
+	tokenType := token.TokenType
+
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+
+	req.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+
+	return nil
+}
+
+// Challenge retries the request once with a new token if the token was
+// rejected with invalid_token.
+func (s *OAuth2TokenSource) Challenge(req *http.Request, res *http.Response, attempt int) (retry bool, err error) {
+	if attempt > 1 {
+		return false, nil
+	}
+
+	challenge, ok := findAuthChallenge(res, "bearer")
+
+	if !ok || challenge.Params["error"] != "invalid_token" {
+		return false, nil
+	}
+
+	_, accessToken, _ := strings.Cut(req.Header.Get("Authorization"), " ")
+
+	s.Invalidate(accessToken)
+
+	return true, nil
+}
+
+// OAuth2JWTAssertion creates signed JWT assertions for the JWT bearer grant.
+// Key can be *rsa.PrivateKey (RS256), *ecdsa.PrivateKey with P-256 (ES256)
+// or ed25519.PrivateKey (EdDSA).
+type OAuth2JWTAssertion struct {
+	Issuer   string
+	Subject  string
+	Audience string
+	KeyID    string
+	Key      crypto.Signer
+	Lifetime time.Duration // defaults to 1 hour
+	Claims   map[string]interface{}
+}
+
+func (a *OAuth2JWTAssertion) Assertion() (assertion string, err error) {
+	var alg string
+
+	switch key := a.Key.(type) {
+	case *rsa.PrivateKey:
+		alg = "RS256"
+	case *ecdsa.PrivateKey:
+		if key.Curve.Params().BitSize != 256 {
+			return "", fmt.Errorf("HTTPClient: unsupported ECDSA curve: %s", key.Curve.Params().Name)
+		}
+		alg = "ES256"
+	case ed25519.PrivateKey:
+		alg = "EdDSA"
+	default:
+		return "", fmt.Errorf("HTTPClient: unsupported JWT key type %T", a.Key)
+	}
+
+	header := map[string]interface{}{
+		"alg": alg,
+		"typ": "JWT",
+	}
+
+	if a.KeyID != "" {
+		header["kid"] = a.KeyID
+	}
+
+	lifetime := a.Lifetime
+
+	if lifetime <= 0 {
+		lifetime = time.Hour
+	}
+
+	now := time.Now()
+
+	claims := map[string]interface{}{}
+
+	for k, v := range a.Claims {
+		claims[k] = v
+	}
+
+	claims["iss"] = a.Issuer
+	claims["aud"] = a.Audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(lifetime).Unix()
+
+	if a.Subject != "" {
+		claims["sub"] = a.Subject
+	}
+
+	headerJSON, err := json.Marshal(header)
+
+	if err != nil {
+		return "", err
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+
+	switch key := a.Key.(type) {
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signingInput))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signingInput))
+
+		var r, s *big.Int
+
+		if r, s, err = ecdsa.Sign(rand.Reader, key, sum[:]); err == nil {
+			// JWS uses the fixed size r || s encoding
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signingInput))
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
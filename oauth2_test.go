@@ -0,0 +1,335 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("OAuth2TokenSource", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var config OAuth2Config
+	var mutex sync.Mutex
+	var tokenRequests []url.Values
+	var tokenHandler func(w http.ResponseWriter, r *http.Request, form url.Values)
+	var invalidTokens map[string]bool
+	var nextToken int
+
+	BeforeEach(func() {
+		tokenRequests = nil
+		invalidTokens = map[string]bool{}
+		nextToken = 0
+
+		tokenHandler = func(w http.ResponseWriter, r *http.Request, form url.Values) {
+			mutex.Lock()
+			nextToken++
+			token := fmt.Sprintf("token%d", nextToken)
+			mutex.Unlock()
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  token,
+				"token_type":    "bearer",
+				"expires_in":    3600,
+				"refresh_token": "refresh-" + token,
+			})
+		}
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+
+			if r.URL.Path == "/token" {
+				Expect(r.ParseForm()).To(Succeed())
+
+				mutex.Lock()
+				tokenRequests = append(tokenRequests, r.PostForm)
+				mutex.Unlock()
+
+				tokenHandler(w, r, r.PostForm)
+				return
+			}
+
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+			mutex.Lock()
+			invalid := invalidTokens[token]
+			mutex.Unlock()
+
+			if invalid {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token", error_description="The access token expired"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			w.Write([]byte(token + ":" + string(body)))
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+
+		config = OAuth2Config{
+			Client:       client,
+			TokenURL:     ts.URL + "/token",
+			ClientID:     "client id",
+			ClientSecret: "secret",
+			Scopes:       []string{"read", "write"},
+		}
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	get := func(req *RequestData) string {
+		res, err := client.Request(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		Expect(err).NotTo(HaveOccurred())
+
+		return string(body)
+	}
+
+	It("should acquire token with client credentials and reuse it", func() {
+		tokenHandler = func(w http.ResponseWriter, r *http.Request, form url.Values) {
+			id, secret, ok := r.BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(id).To(Equal("client+id"))
+			Expect(secret).To(Equal("secret"))
+
+			w.Write([]byte(`{"access_token":"token1","token_type":"Bearer","expires_in":3600}`))
+		}
+
+		client.SetAuthenticator(NewOAuth2ClientCredentials(config))
+
+		Expect(get(&RequestData{Method: "GET", Path: "/"})).To(Equal("token1:"))
+		Expect(get(&RequestData{Method: "GET", Path: "/"})).To(Equal("token1:"))
+
+		Expect(tokenRequests).To(HaveLen(1))
+		Expect(tokenRequests[0].Get("grant_type")).To(Equal("client_credentials"))
+		Expect(tokenRequests[0].Get("scope")).To(Equal("read write"))
+	})
+
+	It("should send client credentials in params", func() {
+		config.AuthInParams = true
+
+		_, err := NewOAuth2ClientCredentials(config).Token(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(tokenRequests[0].Get("client_id")).To(Equal("client id"))
+		Expect(tokenRequests[0].Get("client_secret")).To(Equal("secret"))
+	})
+
+	It("should not compress token requests", func() {
+		client.SetReqCompression(CompressionGzip, 0)
+
+		_, err := NewOAuth2ClientCredentials(config).Token(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(tokenRequests[0].Get("grant_type")).To(Equal("client_credentials"))
+	})
+
+	It("should refresh token before expiry", func() {
+		config.ExpiryDelta = time.Hour + time.Minute
+
+		source := NewOAuth2ClientCredentials(config)
+
+		token, err := source.Token(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.AccessToken).To(Equal("token1"))
+
+		token, err = source.Token(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.AccessToken).To(Equal("token2"))
+	})
+
+	It("should deduplicate concurrent refreshes", func() {
+		tokenHandler = func(w http.ResponseWriter, r *http.Request, form url.Values) {
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte(`{"access_token":"token1","expires_in":3600}`))
+		}
+
+		source := NewOAuth2ClientCredentials(config)
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				token, err := source.Token(context.Background())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(token.AccessToken).To(Equal("token1"))
+			}()
+		}
+
+		wg.Wait()
+
+		Expect(tokenRequests).To(HaveLen(1))
+	})
+
+	It("should use rotated refresh tokens", func() {
+		config.ExpiryDelta = 2 * time.Hour
+
+		source := NewOAuth2RefreshToken(config, "initial")
+
+		_, err := source.Token(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = source.Token(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(tokenRequests).To(HaveLen(2))
+		Expect(tokenRequests[0].Get("grant_type")).To(Equal("refresh_token"))
+		Expect(tokenRequests[0].Get("refresh_token")).To(Equal("initial"))
+		Expect(tokenRequests[1].Get("refresh_token")).To(Equal("refresh-token1"))
+	})
+
+	It("should acquire token with JWT bearer assertion", func() {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, key := range []crypto.Signer{rsaKey, ecKey} {
+			tokenRequests = nil
+
+			assertion := &OAuth2JWTAssertion{
+				Issuer:   "issuer",
+				Subject:  "subject",
+				Audience: config.TokenURL,
+				KeyID:    "key1",
+				Key:      key,
+				Claims:   map[string]interface{}{"scope": "read"},
+			}
+
+			_, err = NewOAuth2JWTBearer(config, assertion.Assertion).Token(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(tokenRequests[0].Get("grant_type")).To(Equal("urn:ietf:params:oauth:grant-type:jwt-bearer"))
+
+			parts := strings.Split(tokenRequests[0].Get("assertion"), ".")
+			Expect(parts).To(HaveLen(3))
+
+			var header, claims map[string]interface{}
+			headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+			claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			Expect(json.Unmarshal(headerJSON, &header)).To(Succeed())
+			Expect(json.Unmarshal(claimsJSON, &claims)).To(Succeed())
+			Expect(header["kid"]).To(Equal("key1"))
+			Expect(claims["iss"]).To(Equal("issuer"))
+			Expect(claims["sub"]).To(Equal("subject"))
+			Expect(claims["aud"]).To(Equal(config.TokenURL))
+			Expect(claims["scope"]).To(Equal("read"))
+			Expect(claims["exp"].(float64) - claims["iat"].(float64)).To(Equal(3600.0))
+
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+			switch key := key.(type) {
+			case *rsa.PrivateKey:
+				Expect(header["alg"]).To(Equal("RS256"))
+				Expect(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], signature)).To(Succeed())
+			case *ecdsa.PrivateKey:
+				Expect(header["alg"]).To(Equal("ES256"))
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+				Expect(ecdsa.Verify(&key.PublicKey, sum[:], r, s)).To(BeTrue())
+			}
+		}
+	})
+
+	It("should retry once on invalid_token", func() {
+		source := NewOAuth2ClientCredentials(config)
+		client.SetAuthenticator(source)
+
+		Expect(get(&RequestData{Method: "GET", Path: "/"})).To(Equal("token1:"))
+
+		mutex.Lock()
+		invalidTokens["token1"] = true
+		mutex.Unlock()
+
+		Expect(get(&RequestData{
+			Method:    "POST",
+			Path:      "/",
+			ReqReader: bytes.NewReader([]byte("body")),
+		})).To(Equal("token2:body"))
+
+		mutex.Lock()
+		invalidTokens["token2"] = true
+		invalidTokens["token3"] = true
+		mutex.Unlock()
+
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			ExpectedStatus: []int{http.StatusOK},
+		})
+		Expect(IsInvalidStatusCode(err, http.StatusUnauthorized)).To(BeTrue())
+		Expect(tokenRequests).To(HaveLen(3))
+	})
+
+	It("should not retry without invalid_token error", func() {
+		ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/token" {
+				w.Write([]byte(`{"access_token":"token1"}`))
+				return
+			}
+
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="insufficient_scope"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+
+		client.SetAuthenticator(NewOAuth2ClientCredentials(config))
+
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			ExpectedStatus: []int{http.StatusOK},
+		})
+		Expect(IsInvalidStatusCode(err, http.StatusUnauthorized)).To(BeTrue())
+	})
+
+	It("should return OAuth2Error from token endpoint", func() {
+		tokenHandler = func(w http.ResponseWriter, r *http.Request, form url.Values) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"Unknown client"}`))
+		}
+
+		_, err := NewOAuth2ClientCredentials(config).Token(context.Background())
+		Expect(err).To(Equal(OAuth2Error{
+			StatusCode:  http.StatusBadRequest,
+			Code:        "invalid_client",
+			Description: "Unknown client",
+		}))
+	})
+})
@@ -0,0 +1,66 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+)
+
+// PublicKeyPin returns the pin of the certificate's public key, the base64
+// SHA-256 of the SubjectPublicKeyInfo (e.g. from openssl x509 -pubkey |
+// openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64).
+func PublicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// pinVerifier returns a tls.Config.VerifyConnection function that requires a
+// certificate of a verified chain to match one of the pins. If the chain is
+// not verified (InsecureSkipVerify) only the leaf certificate is checked
+// because the other certificates sent by the server prove nothing.
+func pinVerifier(options *TLSOptions) func(tls.ConnectionState) error {
+	pins := map[string]bool{}
+
+	for _, pin := range append(append([]string{}, options.Pins...), options.BackupPins...) {
+		pins[strings.TrimPrefix(pin, "sha256/")] = true
+	}
+
+	return func(state tls.ConnectionState) error {
+		var certs []*x509.Certificate
+
+		for _, chain := range state.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+
+		if len(state.VerifiedChains) == 0 && len(state.PeerCertificates) > 0 {
+			certs = state.PeerCertificates[:1]
+		}
+
+		for _, cert := range certs {
+			if pins[PublicKeyPin(cert)] {
+				return nil
+			}
+		}
+
+		pinningErr := PinningError{
+			Host: state.ServerName,
+			Pins: append(append([]string{}, options.Pins...), options.BackupPins...),
+		}
+
+		for _, cert := range state.PeerCertificates {
+			pinningErr.Actual = append(pinningErr.Actual, PublicKeyPin(cert))
+		}
+
+		if options.OnPinFailure != nil {
+			options.OnPinFailure(pinningErr)
+		}
+
+		if options.PinReportOnly {
+			return nil
+		}
+
+		return pinningErr
+	}
+}
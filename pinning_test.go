@@ -0,0 +1,126 @@
+package httpclient_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("Pinning", func() {
+	var ca *testCert
+	var serverCert *testCert
+	var ts *httptest.Server
+	var client *HTTPClient
+
+	BeforeEach(func() {
+		ca = newTestCert("ca", nil)
+		serverCert = newTestCert("server", ca)
+
+		ts = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		// the CA is sent as well to check that unverified chain certificates
+		// are not accepted
+		cert := serverCert.tlsCertificate()
+		cert.Certificate = append(cert.Certificate, ca.cert.Raw)
+
+		ts.TLS = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}
+		ts.StartTLS()
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.BaseURL = u
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	get := func() error {
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			ExpectedStatus: []int{http.StatusOK},
+			RespConsume:    true,
+		})
+		return err
+	}
+
+	It("should accept the leaf or CA public key", func() {
+		Expect(client.SetTLS(TLSOptions{
+			RootCAPEM: ca.certPEM,
+			Pins:      []string{"sha256/" + PublicKeyPin(serverCert.cert)},
+		})).To(Succeed())
+		Expect(get()).To(Succeed())
+
+		Expect(client.SetTLS(TLSOptions{
+			RootCAPEM: ca.certPEM,
+			Pins:      []string{PublicKeyPin(ca.cert)},
+		})).To(Succeed())
+		Expect(get()).To(Succeed())
+	})
+
+	It("should accept backup pins", func() {
+		Expect(client.SetTLS(TLSOptions{
+			RootCAPEM:  ca.certPEM,
+			Pins:       []string{PublicKeyPin(newTestCert("old", ca).cert)},
+			BackupPins: []string{PublicKeyPin(serverCert.cert)},
+		})).To(Succeed())
+		Expect(get()).To(Succeed())
+	})
+
+	It("should fail with PinningError", func() {
+		otherPin := PublicKeyPin(newTestCert("other", ca).cert)
+
+		Expect(client.SetTLS(TLSOptions{
+			RootCAPEM: ca.certPEM,
+			Pins:      []string{otherPin},
+		})).To(Succeed())
+
+		err := get()
+		pinningErr, ok := IsPinningError(err)
+		Expect(ok).To(BeTrue())
+		Expect(pinningErr.Pins).To(Equal([]string{otherPin}))
+		Expect(pinningErr.Actual).To(Equal([]string{PublicKeyPin(serverCert.cert), PublicKeyPin(ca.cert)}))
+	})
+
+	It("should only report in report-only mode", func() {
+		var reported *PinningError
+
+		Expect(client.SetTLS(TLSOptions{
+			RootCAPEM:     ca.certPEM,
+			Pins:          []string{PublicKeyPin(newTestCert("other", ca).cert)},
+			PinReportOnly: true,
+			OnPinFailure: func(err PinningError) {
+				reported = &err
+			},
+		})).To(Succeed())
+
+		Expect(get()).To(Succeed())
+		Expect(reported).NotTo(BeNil())
+		Expect(reported.Actual).To(HaveLen(2))
+	})
+
+	It("should check pins of unverified certificates", func() {
+		Expect(client.SetTLS(TLSOptions{
+			InsecureSkipVerify: true,
+			Pins:               []string{PublicKeyPin(serverCert.cert)},
+		})).To(Succeed())
+		Expect(get()).To(Succeed())
+
+		Expect(client.SetTLS(TLSOptions{
+			InsecureSkipVerify: true,
+			Pins:               []string{PublicKeyPin(ca.cert)},
+		})).To(Succeed())
+
+		_, ok := IsPinningError(get())
+		Expect(ok).To(BeTrue())
+	})
+})
@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"io"
+	"time"
+)
+
+var DefaultProgressInterval = 500 * time.Millisecond
+
+type Progress struct {
+	Transferred int64
+	Total       int64         // -1 if unknown
+	Rate        float64       // bytes per second
+	ETA         time.Duration // -1 if unknown
+	Done        bool
+}
+
+type ProgressFunc func(Progress)
+
+// ProgressChan sends the updates to ch. Updates are dropped while ch is full,
+// except the last one with Done which is sent once ch has room.
+func ProgressChan(ch chan<- Progress) ProgressFunc {
+	return func(p Progress) {
+		select {
+		case ch <- p:
+		default:
+			if p.Done {
+				go func() {
+					ch <- p
+				}()
+			}
+		}
+	}
+}
+
+type progressReader struct {
+	reader      io.ReadCloser
+	total       int64
+	transferred int64
+	interval    time.Duration
+	onProgress  ProgressFunc
+	start       time.Time
+	lastReport  time.Time
+	done        bool
+}
+
+func newProgressReader(reader io.ReadCloser, total int64, interval time.Duration, onProgress ProgressFunc) *progressReader {
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+
+	if total <= 0 {
+		total = -1
+	}
+
+	now := time.Now()
+
+	return &progressReader{
+		reader:     reader,
+		total:      total,
+		interval:   interval,
+		onProgress: onProgress,
+		start:      now,
+		lastReport: now,
+	}
+}
+
+func (r *progressReader) report(now time.Time) {
+	p := Progress{
+		Transferred: r.transferred,
+		Total:       r.total,
+		ETA:         -1,
+		Done:        r.done,
+	}
+
+	if elapsed := now.Sub(r.start).Seconds(); elapsed > 0 {
+		p.Rate = float64(r.transferred) / elapsed
+	}
+
+	if r.done {
+		p.ETA = 0
+	} else if r.total > 0 && p.Rate > 0 {
+		p.ETA = time.Duration(float64(r.total-r.transferred) / p.Rate * float64(time.Second))
+	}
+
+	r.lastReport = now
+
+	r.onProgress(p)
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+
+	r.transferred += int64(n)
+
+	if r.done {
+		return n, err
+	}
+
+	now := time.Now()
+
+	if err == io.EOF {
+		r.done = true
+		r.report(now)
+	} else if now.Sub(r.lastReport) >= r.interval {
+		r.report(now)
+	}
+
+	return n, err
+}
+
+func (r *progressReader) Close() error {
+	return r.reader.Close()
+}
@@ -0,0 +1,219 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+type slowReader struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (n int, err error) {
+	time.Sleep(r.delay)
+
+	if len(p) > 10 {
+		p = p[:10]
+	}
+
+	return r.r.Read(p)
+}
+
+var _ = Describe("Progress", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var handler func(http.ResponseWriter, *http.Request)
+
+	BeforeEach(func() {
+		handler = nil
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			handler(w, r)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	It("should report upload progress", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+		}
+
+		var updates []Progress
+
+		_, err := client.Request(&RequestData{
+			Method:           "POST",
+			Path:             "/",
+			ReqReader:        bytes.NewReader(bytes.Repeat([]byte("x"), 100)),
+			ReqContentLength: 100,
+			ReqProgress: func(p Progress) {
+				updates = append(updates, p)
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(updates).NotTo(BeEmpty())
+		last := updates[len(updates)-1]
+		Expect(last.Transferred).To(Equal(int64(100)))
+		Expect(last.Total).To(Equal(int64(100)))
+		Expect(last.Done).To(BeTrue())
+		Expect(last.ETA).To(Equal(time.Duration(0)))
+	})
+
+	It("should report download progress", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "100")
+			w.Write(bytes.Repeat([]byte("x"), 100))
+		}
+
+		var updates []Progress
+		var data []byte
+
+		_, err := client.Request(&RequestData{
+			Method:    "GET",
+			Path:      "/",
+			RespValue: &data,
+			RespProgress: func(p Progress) {
+				updates = append(updates, p)
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(HaveLen(100))
+
+		Expect(updates).NotTo(BeEmpty())
+		last := updates[len(updates)-1]
+		Expect(last.Transferred).To(Equal(int64(100)))
+		Expect(last.Total).To(Equal(int64(100)))
+		Expect(last.Done).To(BeTrue())
+	})
+
+	It("should throttle updates and estimate remaining time", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "100")
+			w.(http.Flusher).Flush()
+			io.Copy(w, &slowReader{r: strings.NewReader(strings.Repeat("x", 100)), delay: 10 * time.Millisecond})
+		}
+
+		var updates []Progress
+
+		res, err := client.Request(&RequestData{
+			Method:           "GET",
+			Path:             "/",
+			ProgressInterval: 30 * time.Millisecond,
+			RespProgress: func(p Progress) {
+				updates = append(updates, p)
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = io.Copy(io.Discard, res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+
+		Expect(len(updates)).To(BeNumerically(">=", 2))
+		Expect(len(updates)).To(BeNumerically("<", 10))
+
+		first := updates[0]
+		Expect(first.Done).To(BeFalse())
+		Expect(first.Total).To(Equal(int64(100)))
+		Expect(first.Rate).To(BeNumerically(">", 0))
+		Expect(first.ETA).To(BeNumerically(">", 0))
+
+		Expect(updates[len(updates)-1].Done).To(BeTrue())
+	})
+
+	It("should report unknown total", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+		}
+
+		ch := make(chan Progress, 10)
+
+		_, err := client.Request(&RequestData{
+			Method:      "POST",
+			Path:        "/",
+			ReqReader:   &onlyReader{strings.NewReader("body")},
+			ReqProgress: ProgressChan(ch),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		var last Progress
+		Eventually(ch).Should(Receive(&last))
+		Expect(last.Total).To(Equal(int64(-1)))
+		Expect(last.ETA).To(Equal(time.Duration(0)))
+		Expect(last.Done).To(BeTrue())
+	})
+	It("should report progress of replayed body", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/redirect" {
+				http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+				return
+			}
+
+			io.ReadAll(r.Body)
+		}
+
+		var updates []Progress
+
+		_, err := client.Request(&RequestData{
+			Method:    "POST",
+			Path:      "/redirect",
+			ReqReader: StringBody("body"),
+			ReqProgress: func(p Progress) {
+				updates = append(updates, p)
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		done := 0
+
+		for _, p := range updates {
+			if p.Done {
+				Expect(p.Transferred).To(Equal(int64(4)))
+				done++
+			}
+		}
+
+		Expect(done).To(Equal(2))
+	})
+
+	It("should send done update to full channel", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+		}
+
+		ch := make(chan Progress)
+
+		_, err := client.Request(&RequestData{
+			Method:      "POST",
+			Path:        "/",
+			ReqReader:   strings.NewReader("body"),
+			ReqProgress: ProgressChan(ch),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		var last Progress
+		Eventually(ch).Should(Receive(&last))
+		Expect(last.Done).To(BeTrue())
+	})
+})
@@ -0,0 +1,219 @@
+package httpclient
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ReplayableReader is a request body that can be read again from the start,
+// e.g. to retry a request or follow a 307/308 redirect.
+type ReplayableReader interface {
+	io.Reader
+
+	// Reopen returns a new reader positioned at the start of the body.
+	Reopen() (io.ReadCloser, error)
+
+	// Size returns the size of the body or -1 if it is unknown.
+	Size() int64
+}
+
+type replayableReader struct {
+	open    func() (io.ReadCloser, error)
+	size    int64
+	current io.ReadCloser
+}
+
+func (r *replayableReader) Read(p []byte) (n int, err error) {
+	if r.current == nil {
+		if r.current, err = r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	return r.current.Read(p)
+}
+
+func (r *replayableReader) Reopen() (io.ReadCloser, error) {
+	return r.open()
+}
+
+func (r *replayableReader) Size() int64 {
+	return r.size
+}
+
+func BytesBody(b []byte) ReplayableReader {
+	return &replayableReader{
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		},
+		size: int64(len(b)),
+	}
+}
+
+func StringBody(s string) ReplayableReader {
+	return &replayableReader{
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(s)), nil
+		},
+		size: int64(len(s)),
+	}
+}
+
+// SeekerBody replays rs from its current offset. The reopened readers keep
+// their own offsets, e.g. a body can be hashed while it is being sent.
+func SeekerBody(rs io.ReadSeeker) (ReplayableReader, error) {
+	start, err := rs.Seek(0, io.SeekCurrent)
+
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := rs.Seek(0, io.SeekEnd)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = rs.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	s := &sharedSeeker{
+		rs: rs,
+	}
+
+	return &replayableReader{
+		open: func() (io.ReadCloser, error) {
+			return &seekerReader{
+				seeker: s,
+				offset: start,
+			}, nil
+		},
+		size: end - start,
+	}, nil
+}
+
+// sharedSeeker remembers which seekerReader moved the offset of rs last.
+type sharedSeeker struct {
+	mutex sync.Mutex
+	rs    io.ReadSeeker
+	owner *seekerReader
+}
+
+type seekerReader struct {
+	seeker *sharedSeeker
+	offset int64
+}
+
+func (r *seekerReader) Read(p []byte) (n int, err error) {
+	s := r.seeker
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.owner != r {
+		if _, err = s.rs.Seek(r.offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+
+		s.owner = r
+	}
+
+	n, err = s.rs.Read(p)
+	r.offset += int64(n)
+
+	return n, err
+}
+
+func (r *seekerReader) Close() error {
+	return nil
+}
+
+// FuncBody calls open every time the body is read from the start. size can be
+// -1 if it is unknown.
+func FuncBody(open func() (io.ReadCloser, error), size int64) ReplayableReader {
+	return &replayableReader{
+		open: open,
+		size: size,
+	}
+}
+
+// SpooledBody is a ReplayableReader for a body that can only be read once. It
+// must be closed to remove the temporary file.
+type SpooledBody struct {
+	replayableReader
+	path string
+}
+
+// SpoolBody reads r to memory, or to a temporary file once it exceeds
+// maxMemory bytes.
+func SpoolBody(r io.Reader, maxMemory int64) (body *SpooledBody, err error) {
+	var buf bytes.Buffer
+
+	n, err := io.Copy(&buf, io.LimitReader(r, maxMemory+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n <= maxMemory {
+		b := buf.Bytes()
+
+		return &SpooledBody{
+			replayableReader: *BytesBody(b).(*replayableReader),
+		}, nil
+	}
+
+	f, err := os.CreateTemp("", "httpclient-spool-*")
+
+	if err != nil {
+		return nil, err
+	}
+
+	path := f.Name()
+
+	defer func() {
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	if _, err = buf.WriteTo(f); err == nil {
+		var rest int64
+		rest, err = io.Copy(f, r)
+		n += rest
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &SpooledBody{
+		replayableReader: replayableReader{
+			open: func() (io.ReadCloser, error) {
+				return os.Open(path)
+			},
+			size: n,
+		},
+		path: path,
+	}, nil
+}
+
+func (b *SpooledBody) Close() error {
+	if b.current != nil {
+		b.current.Close()
+	}
+
+	if b.path == "" {
+		return nil
+	}
+
+	return os.Remove(b.path)
+}
@@ -0,0 +1,224 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("ReplayableReader", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var bodies []string
+	var lengths []int64
+
+	BeforeEach(func() {
+		bodies = nil
+		lengths = nil
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+
+			body := decompressBody(r)
+
+			bodies = append(bodies, string(body))
+			lengths = append(lengths, r.ContentLength)
+
+			if r.URL.Path == "/redirect" {
+				http.Redirect(w, r, "/target", http.StatusTemporaryRedirect)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	readAll := func(body ReplayableReader) string {
+		r, err := body.Reopen()
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+
+		data, err := io.ReadAll(r)
+		Expect(err).NotTo(HaveOccurred())
+
+		return string(data)
+	}
+
+	It("should reopen bytes, string, seeker and func bodies", func() {
+		seeker := strings.NewReader("xxseeker")
+		seeker.Seek(2, io.SeekStart)
+
+		seekerBody, err := SeekerBody(seeker)
+		Expect(err).NotTo(HaveOccurred())
+
+		funcBody := FuncBody(func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("func")), nil
+		}, -1)
+
+		for _, body := range []ReplayableReader{BytesBody([]byte("bytes")), StringBody("string"), seekerBody, funcBody} {
+			first := readAll(body)
+			Expect(readAll(body)).To(Equal(first))
+		}
+
+		Expect(readAll(seekerBody)).To(Equal("seeker"))
+		Expect(seekerBody.Size()).To(Equal(int64(6)))
+		Expect(funcBody.Size()).To(Equal(int64(-1)))
+	})
+
+	It("should keep the offsets of reopened seeker readers", func() {
+		body, err := SeekerBody(strings.NewReader("seeker"))
+		Expect(err).NotTo(HaveOccurred())
+
+		a, _ := body.Reopen()
+		b, _ := body.Reopen()
+
+		buf := make([]byte, 3)
+		io.ReadFull(a, buf)
+		Expect(string(buf)).To(Equal("see"))
+
+		Expect(io.ReadAll(b)).To(Equal([]byte("seeker")))
+		Expect(io.ReadAll(a)).To(Equal([]byte("ker")))
+	})
+
+	It("should spool large bodies to a temporary file", func() {
+		content := bytes.Repeat([]byte("0123456789"), 100)
+
+		body, err := SpoolBody(bytes.NewReader(content), 10)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body.Size()).To(Equal(int64(len(content))))
+		Expect(readAll(body)).To(Equal(string(content)))
+		Expect(readAll(body)).To(Equal(string(content)))
+
+		count := func() (n int) {
+			entries, err := os.ReadDir(os.TempDir())
+			Expect(err).NotTo(HaveOccurred())
+			for _, e := range entries {
+				if strings.HasPrefix(e.Name(), "httpclient-spool-") {
+					n++
+				}
+			}
+			return n
+		}
+		before := count()
+		Expect(before).To(BeNumerically(">", 0))
+
+		Expect(body.Close()).To(Succeed())
+		Expect(count()).To(Equal(before - 1))
+
+		small, err := SpoolBody(strings.NewReader("small"), 10)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(readAll(small)).To(Equal("small"))
+		Expect(small.Close()).To(Succeed())
+	})
+
+	It("should copy request with replayable body", func() {
+		req := &RequestData{
+			Method:         "POST",
+			Path:           "/",
+			ReqReader:      StringBody("body"),
+			ExpectedStatus: []int{http.StatusOK},
+			RespConsume:    true,
+		}
+
+		Expect(req.CanCopy()).To(BeTrue())
+
+		_, err := client.Request(req)
+		Expect(err).NotTo(HaveOccurred())
+
+		ok, nr := req.Copy()
+		Expect(ok).To(BeTrue())
+
+		_, err = client.Request(nr)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(bodies).To(Equal([]string{"body", "body"}))
+		Expect(lengths).To(Equal([]int64{4, 4}))
+
+		req.ReqReader = strings.NewReader("body")
+		Expect(req.CanCopy()).To(BeFalse())
+	})
+
+	It("should follow 307 redirect with replayable body", func() {
+		_, err := client.Request(&RequestData{
+			Method:         "POST",
+			Path:           "/redirect",
+			ReqReader:      BytesBody([]byte("body")),
+			ExpectedStatus: []int{http.StatusOK},
+			RespConsume:    true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bodies).To(Equal([]string{"body", "body"}))
+	})
+
+	It("should follow 307 redirect with compressed replayable body", func() {
+		_, err := client.Request(&RequestData{
+			Method:         "POST",
+			Path:           "/redirect",
+			ReqReader:      StringBody(strings.Repeat("body", 100)),
+			ReqCompression: CompressionGzip,
+			ExpectedStatus: []int{http.StatusOK},
+			RespConsume:    true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bodies).To(Equal([]string{strings.Repeat("body", 100), strings.Repeat("body", 100)}))
+	})
+	It("should close reopened body if request is not sent", func() {
+		opened := 0
+		closed := 0
+
+		body := FuncBody(func() (io.ReadCloser, error) {
+			opened++
+			return closeCounter{Reader: strings.NewReader("body"), closed: &closed}, nil
+		}, 4)
+
+		_, err := client.Request(&RequestData{
+			Method:    "POST",
+			Path:      "/",
+			ReqReader: body,
+			ReqDigest: "invalid",
+		})
+		Expect(err).To(HaveOccurred())
+
+		client.SetRateLimit(0, 10*time.Millisecond)
+
+		_, err = client.Request(&RequestData{
+			Method:    "POST",
+			Path:      "/",
+			ReqReader: body,
+		})
+		Expect(err).To(Equal(RateLimitTimeoutError))
+
+		Expect(opened).To(Equal(2))
+		Expect(closed).To(Equal(opened))
+	})
+})
+
+type closeCounter struct {
+	io.Reader
+	closed *int
+}
+
+func (c closeCounter) Close() error {
+	*c.closed++
+	return nil
+}
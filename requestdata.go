@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type Encoding string
@@ -16,26 +17,46 @@ const (
 )
 
 type RequestData struct {
-	Context          context.Context
-	Method           string
-	Path             string
-	Params           url.Values
-	FullURL          string // client.BaseURL + Path or FullURL
-	Headers          http.Header
-	ReqReader        io.Reader
-	ReqEncoding      Encoding
-	ReqValue         interface{}
-	ReqContentLength int64
-	ExpectedStatus   []int
-	IgnoreRedirects  bool
-	RespEncoding     Encoding
-	RespValue        interface{}
-	RespConsume      bool
+	Context               context.Context
+	Method                string
+	Path                  string
+	Params                url.Values
+	FullURL               string // client.BaseURL + Path or FullURL
+	Headers               http.Header
+	ReqReader             io.Reader
+	ReqEncoding           Encoding
+	ReqValue              interface{}
+	ReqContentLength      int64
+	ReqCompression        string
+	ReqCompressionMinSize int64
+	ExpectContinue        bool // requires Transport.ExpectContinueTimeout
+	ExpectedStatus        []int
+	IgnoreRedirects       bool
+	RespEncoding          Encoding
+	RespValue             interface{}
+	RespConsume           bool
+	DisableDecompression  bool
+	ReqProgress           ProgressFunc
+	RespProgress          ProgressFunc
+	ProgressInterval      time.Duration
+	UploadLimiter         *BandwidthLimiter
+	DownloadLimiter       *BandwidthLimiter
+	IdleTimeout           time.Duration // max time without body data transferred
+	MinThroughput         int64         // bytes per second, measured over MinThroughputWindow
+	MinThroughputWindow   time.Duration
+	ReqDigest             string // DigestSHA256, DigestSHA512 or DigestMD5
+	VerifyDigest          bool
+	VerifySignature       *MessageVerifier
+	Authenticator         Authenticator // overrides the client authenticator
+
+	// beforeDecompress is called with the content-coded response
+	beforeDecompress func(res *http.Response)
 }
 
 func (r *RequestData) CanCopy() bool {
 	if r.ReqReader != nil {
-		return false
+		_, ok := r.ReqReader.(ReplayableReader)
+		return ok
 	}
 
 	return true
@@ -47,15 +68,33 @@ func (r *RequestData) Copy() (ok bool, nr *RequestData) {
 	}
 
 	nr = &RequestData{
-		Method:          r.Method,
-		Path:            r.Path,
-		FullURL:         r.FullURL,
-		ReqEncoding:     r.ReqEncoding,
-		ReqValue:        r.ReqValue,
-		IgnoreRedirects: r.IgnoreRedirects,
-		RespEncoding:    r.RespEncoding,
-		RespValue:       r.RespValue,
-		RespConsume:     r.RespConsume,
+		Method:                r.Method,
+		Path:                  r.Path,
+		FullURL:               r.FullURL,
+		ReqReader:             r.ReqReader,
+		ReqEncoding:           r.ReqEncoding,
+		ReqValue:              r.ReqValue,
+		ReqContentLength:      r.ReqContentLength,
+		ReqCompression:        r.ReqCompression,
+		ReqCompressionMinSize: r.ReqCompressionMinSize,
+		ExpectContinue:        r.ExpectContinue,
+		IgnoreRedirects:       r.IgnoreRedirects,
+		RespEncoding:          r.RespEncoding,
+		RespValue:             r.RespValue,
+		RespConsume:           r.RespConsume,
+		DisableDecompression:  r.DisableDecompression,
+		ReqProgress:           r.ReqProgress,
+		RespProgress:          r.RespProgress,
+		ProgressInterval:      r.ProgressInterval,
+		UploadLimiter:         r.UploadLimiter,
+		DownloadLimiter:       r.DownloadLimiter,
+		IdleTimeout:           r.IdleTimeout,
+		MinThroughput:         r.MinThroughput,
+		MinThroughputWindow:   r.MinThroughputWindow,
+		ReqDigest:             r.ReqDigest,
+		VerifyDigest:          r.VerifyDigest,
+		VerifySignature:       r.VerifySignature,
+		Authenticator:         r.Authenticator,
 	}
 
 	if r.Params != nil {
@@ -1,9 +1,10 @@
 package httpclient
 
 import (
+	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
+	"sort"
 )
 
 func (req *RequestData) UploadFile(fieldName string, fileName string, reader io.Reader) (err error) {
@@ -11,43 +12,38 @@ func (req *RequestData) UploadFile(fieldName string, fileName string, reader io.
 }
 
 func (req *RequestData) UploadFileExtra(fieldName string, fileName string, reader io.Reader, extra map[string]string) (err error) {
-	r, w := io.Pipe()
+	form := NewMultipartForm()
 
-	writer := multipart.NewWriter(w)
+	keys := make([]string, 0, len(extra))
 
-	go func() {
-		var err error
+	for k := range extra {
+		keys = append(keys, k)
+	}
 
-		defer func() {
-			if err == nil {
-				w.Close()
-			}
-		}()
+	sort.Strings(keys)
 
-		for k, v := range extra {
-			err = writer.WriteField(k, v)
+	for _, k := range keys {
+		form.AddField(k, extra[k])
+	}
+
+	form.AddFile(fieldName, fileName, reader)
 
-			if err != nil {
-				w.CloseWithError(err)
-				return
-			}
-		}
+	return req.UploadMultipartForm(form)
+}
 
-		part, err := writer.CreateFormFile(fieldName, fileName)
+func (req *RequestData) UploadMultipartForm(form *MultipartForm) (err error) {
+	r, w := io.Pipe()
 
-		if err != nil {
-			w.CloseWithError(err)
-			return
-		}
+	writer, err := form.newWriter(w)
 
-		defer writer.Close()
+	if err != nil {
+		return err
+	}
 
-		_, err = io.Copy(part, reader)
+	sizes, contentLength, hasContentLength := form.sizes(writer.Boundary())
 
-		if err != nil {
-			w.CloseWithError(err)
-			return
-		}
+	go func() {
+		w.CloseWithError(form.writeTo(writer, sizes))
 	}()
 
 	req.ReqReader = r
@@ -58,5 +54,11 @@ func (req *RequestData) UploadFileExtra(fieldName string, fileName string, reade
 
 	req.Headers.Set("Content-Type", writer.FormDataContentType())
 
+	if hasContentLength {
+		req.Headers.Set("Content-Length", fmt.Sprintf("%d", contentLength))
+
+		req.ReqContentLength = contentLength
+	}
+
 	return
 }
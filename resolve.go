@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// HostResolver resolves host names to IP addresses. net.Resolver implements
+// it.
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+}
+
+type dnsCacheEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// DNSCache caches the addresses returned by Resolver for TTL. Errors are not
+// cached.
+type DNSCache struct {
+	Resolver HostResolver
+	TTL      time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]dnsCacheEntry
+}
+
+func NewDNSCache(resolver HostResolver, ttl time.Duration) *DNSCache {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	return &DNSCache{
+		Resolver: resolver,
+		TTL:      ttl,
+		entries:  make(map[string]dnsCacheEntry),
+	}
+}
+
+func (c *DNSCache) LookupHost(ctx context.Context, host string) (addrs []string, err error) {
+	now := time.Now()
+
+	c.mutex.Lock()
+	entry, ok := c.entries[host]
+	c.mutex.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err = c.Resolver.LookupHost(ctx, host)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.entries[host] = dnsCacheEntry{
+		addrs:   addrs,
+		expires: now.Add(c.TTL),
+	}
+	c.mutex.Unlock()
+
+	return addrs, nil
+}
+
+// Clear removes all cached addresses.
+func (c *DNSCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[string]dnsCacheEntry)
+}
+
+// resolveOverride returns the address for addr from overrides. Keys are
+// host:port or host, values are host:port or host (the port of addr is
+// kept).
+func resolveOverride(overrides map[string]string, addr string) (target string, ok bool) {
+	host, port, err := net.SplitHostPort(addr)
+
+	if err != nil {
+		return "", false
+	}
+
+	target, ok = overrides[addr]
+
+	if !ok {
+		target, ok = overrides[host]
+	}
+
+	if !ok {
+		return "", false
+	}
+
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, port)
+	}
+
+	return target, true
+}
+
+// resolvingDialContext dials the override address or the addresses returned
+// by resolver. Only the dialed address changes, TLS server name and Host
+// header still use the original host.
+func resolvingDialContext(dial dialContextFunc, overrides map[string]string, resolver HostResolver) dialContextFunc {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		if target, ok := resolveOverride(overrides, addr); ok {
+			return dial(ctx, network, target)
+		}
+
+		host, port, err := net.SplitHostPort(addr)
+
+		if resolver == nil || err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		addrs, err := resolver.LookupHost(ctx, host)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("HTTPClient: no addresses for host %s", host)
+		}
+
+		// try the addresses in order like net.Dialer
+		var firstErr error
+
+		for _, ip := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+
+			if err == nil {
+				return conn, nil
+			}
+
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		return nil, firstErr
+	}
+}
@@ -0,0 +1,166 @@
+package httpclient_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+type countingResolver struct {
+	mutex sync.Mutex
+	addrs []string
+	err   error
+	calls int
+}
+
+func (r *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.calls++
+
+	return r.addrs, r.err
+}
+
+func (r *countingResolver) Calls() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.calls
+}
+
+var _ = Describe("Resolve", func() {
+	var ts *httptest.Server
+	var port string
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverName := ""
+
+		if r.TLS != nil {
+			serverName = r.TLS.ServerName
+		}
+
+		fmt.Fprintf(w, "%s %s", r.Host, serverName)
+	})
+
+	BeforeEach(func() {
+		ts = httptest.NewServer(handler)
+		_, port, _ = net.SplitHostPort(ts.Listener.Addr().String())
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	get := func(client *HTTPClient, baseURL string) (string, error) {
+		client.BaseURL, _ = url.Parse(baseURL)
+
+		var body []byte
+
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			ExpectedStatus: []int{http.StatusOK},
+			RespValue:      &body,
+		})
+
+		return string(body), err
+	}
+
+	It("should override host:port and host addresses", func() {
+		client, err := NewWithTransport(TransportOptions{
+			Resolve: map[string]string{
+				"api.example.com:80": ts.Listener.Addr().String(),
+				"www.example.com":    "127.0.0.1",
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(get(client, "http://api.example.com")).To(Equal("api.example.com "))
+		Expect(get(client, "http://www.example.com:"+port)).To(Equal("www.example.com:" + port + " "))
+	})
+
+	It("should keep the TLS server name", func() {
+		ca := newTestCert("ca", nil)
+
+		tlsServer := httptest.NewUnstartedServer(handler)
+		tlsServer.TLS = &tls.Config{
+			Certificates: []tls.Certificate{newTestCert("api.example.com", ca).tlsCertificate()},
+		}
+		tlsServer.StartTLS()
+		defer tlsServer.Close()
+
+		client, err := NewWithTransport(TransportOptions{
+			Resolve: map[string]string{
+				"api.example.com:443": tlsServer.Listener.Addr().String(),
+			},
+			TLS: &TLSOptions{
+				RootCAPEM: ca.certPEM,
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(get(client, "https://api.example.com")).To(Equal("api.example.com api.example.com"))
+	})
+
+	It("should use resolver with DNS cache", func() {
+		resolver := &countingResolver{
+			// 127.0.0.2 refuses the connection so the next address is used
+			addrs: []string{"127.0.0.2", "127.0.0.1"},
+		}
+
+		client, err := NewWithTransport(TransportOptions{
+			Resolver:          resolver,
+			DNSCacheTTL:       50 * time.Millisecond,
+			DisableKeepAlives: true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 0; i < 3; i++ {
+			Expect(get(client, "http://api.example.com:"+port)).To(Equal("api.example.com:" + port + " "))
+		}
+
+		Expect(resolver.Calls()).To(Equal(1))
+
+		time.Sleep(60 * time.Millisecond)
+
+		Expect(get(client, "http://api.example.com:"+port)).To(Equal("api.example.com:" + port + " "))
+		Expect(resolver.Calls()).To(Equal(2))
+	})
+
+	It("should not cache errors", func() {
+		resolver := &countingResolver{
+			err: fmt.Errorf("no dns"),
+		}
+
+		cache := NewDNSCache(resolver, time.Minute)
+
+		_, err := cache.LookupHost(context.Background(), "api.example.com")
+		Expect(err).To(MatchError("no dns"))
+
+		resolver.err = nil
+		resolver.addrs = []string{"127.0.0.1"}
+
+		for i := 0; i < 2; i++ {
+			Expect(cache.LookupHost(context.Background(), "api.example.com")).To(Equal([]string{"127.0.0.1"}))
+		}
+
+		Expect(resolver.Calls()).To(Equal(2))
+
+		cache.Clear()
+
+		Expect(cache.LookupHost(context.Background(), "api.example.com")).To(Equal([]string{"127.0.0.1"}))
+		Expect(resolver.Calls()).To(Equal(3))
+	})
+})
@@ -0,0 +1,336 @@
+package httpclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4DateFormat      = "20060102"
+	SigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+var sigV4EmptyHash = hex.EncodeToString(sha256.New().Sum(nil))
+
+// headers that can be changed by the transport or proxies
+var sigV4IgnoredHeaders = map[string]bool{
+	"authorization":     true,
+	"user-agent":        true,
+	"expect":            true,
+	"x-amzn-trace-id":   true,
+	"content-length":    true,
+	"transfer-encoding": true,
+	"connection":        true,
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4. As an Authenticator
+// it signs every attempt, so retries get a new X-Amz-Date.
+type SigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+
+	// UnsignedPayload skips hashing the body. Request bodies without GetBody
+	// can only be signed this way.
+	UnsignedPayload bool
+
+	// DisableURIPathEscaping escapes the path only once. It is always set for
+	// s3.
+	DisableURIPathEscaping bool
+
+	Now func() time.Time // defaults to time.Now
+}
+
+func (s *SigV4Signer) now() time.Time {
+	if s.Now != nil {
+		return s.Now().UTC()
+	}
+
+	return time.Now().UTC()
+}
+
+func (s *SigV4Signer) isS3() bool {
+	return s.Service == "s3"
+}
+
+func (s *SigV4Signer) Authenticate(req *http.Request) error {
+	return s.Sign(req)
+}
+
+func (s *SigV4Signer) Challenge(req *http.Request, res *http.Response, attempt int) (retry bool, err error) {
+	return false, nil
+}
+
+// Sign sets the X-Amz-Date and Authorization headers.
+func (s *SigV4Signer) Sign(req *http.Request) (err error) {
+	now := s.now()
+
+	payloadHash, err := s.payloadHash(req)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	if s.isS3() {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	// send the signed query, e.g. spaces as %20 instead of +
+	req.URL.RawQuery = sigV4CanonicalQueryString(req.URL.RawQuery)
+
+	u, err := parseRequestURL(req.URL)
+
+	if err != nil {
+		return err
+	}
+
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req)
+
+	signature := s.signature(now, req.Method, u, u.RawQuery, canonicalHeaders, signedHeaders, payloadHash)
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm,
+		s.AccessKeyID,
+		s.scope(now),
+		signedHeaders,
+		signature,
+	))
+
+	return nil
+}
+
+// Presign returns a presigned URL for req which is valid for expires. Only the
+// host header is signed and the payload is not.
+func (s *SigV4Signer) Presign(req *http.Request, expires time.Duration) (presignedURL string, err error) {
+	now := s.now()
+
+	u, err := parseRequestURL(req.URL)
+
+	if err != nil {
+		return "", err
+	}
+
+	// copy the URL so that req is not modified
+	nu := *u
+
+	query := nu.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.AccessKeyID+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	if s.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	rawQuery := sigV4CanonicalQuery(query)
+
+	canonicalHeaders := "host:" + sigV4Host(req) + "\n"
+
+	signature := s.signature(now, req.Method, &nu, rawQuery, canonicalHeaders, "host", SigV4UnsignedPayload)
+
+	nu.RawQuery = rawQuery + "&X-Amz-Signature=" + signature
+
+	return nu.String(), nil
+}
+
+func (s *SigV4Signer) payloadHash(req *http.Request) (hash string, err error) {
+	if hash = req.Header.Get("X-Amz-Content-Sha256"); hash != "" {
+		return hash, nil
+	}
+
+	if s.UnsignedPayload {
+		return SigV4UnsignedPayload, nil
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return sigV4EmptyHash, nil
+	}
+
+	if req.GetBody == nil {
+		return "", fmt.Errorf("HTTPClient: SigV4 request body cannot be hashed, use UnsignedPayload or a ReplayableReader")
+	}
+
+	body, err := req.GetBody()
+
+	if err != nil {
+		return "", err
+	}
+
+	defer body.Close()
+
+	h := sha256.New()
+
+	if _, err = io.Copy(h, body); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (s *SigV4Signer) scope(now time.Time) string {
+	return now.Format(sigV4DateFormat) + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+func (s *SigV4Signer) signature(now time.Time, method string, u *url.URL, rawQuery string, canonicalHeaders string, signedHeaders string, payloadHash string) string {
+	canonicalRequest := strings.Join([]string{
+		method,
+		s.canonicalPath(u),
+		sigV4CanonicalQueryString(rawQuery),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		now.Format(sigV4TimeFormat),
+		s.scope(now),
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), now.Format(sigV4DateFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func (s *SigV4Signer) canonicalPath(u *url.URL) string {
+	p := u.Path
+
+	if p == "" {
+		return "/"
+	}
+
+	if s.isS3() || s.DisableURIPathEscaping {
+		return sigV4Escape(p, false)
+	}
+
+	cleaned := path.Clean(p)
+
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return sigV4Escape(sigV4Escape(cleaned, false), false)
+}
+
+func (s *SigV4Signer) canonicalHeaders(req *http.Request) (signedHeaders string, canonicalHeaders string) {
+	headers := map[string][]string{
+		"host": {sigV4Host(req)},
+	}
+
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+
+		if sigV4IgnoredHeaders[name] {
+			continue
+		}
+
+		headers[name] = append(headers[name], values...)
+	}
+
+	names := make([]string, 0, len(headers))
+
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	for _, name := range names {
+		values := make([]string, len(headers[name]))
+
+		for i, v := range headers[name] {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(strings.Join(values, ","))
+		b.WriteString("\n")
+	}
+
+	return strings.Join(names, ";"), b.String()
+}
+
+func sigV4Host(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+
+	return req.URL.Host
+}
+
+func sigV4CanonicalQueryString(rawQuery string) string {
+	query, _ := url.ParseQuery(rawQuery)
+
+	return sigV4CanonicalQuery(query)
+}
+
+func sigV4CanonicalQuery(query url.Values) string {
+	pairs := []string{}
+
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key, true)+"="+sigV4Escape(value, true))
+		}
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape escapes all characters except the unreserved ones (RFC 3986).
+func sigV4Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !escapeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
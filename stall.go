@@ -0,0 +1,223 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+var DefaultMinThroughputWindow = 30 * time.Second
+
+// stallWatchdog cancels ctx if a body transfer stalls. It only runs while a
+// body is transferred, waiting for the response headers is left to
+// Transport.ResponseHeaderTimeout.
+type stallWatchdog struct {
+	ctx               context.Context
+	cancel            context.CancelCauseFunc
+	idleTimeout       time.Duration
+	minThroughput     int64
+	window            time.Duration
+	mutex             sync.Mutex
+	active            bool
+	responding        bool
+	lastActivity      time.Time
+	windowStart       time.Time
+	windowTransferred int64
+	done              chan struct{}
+	stopOnce          sync.Once
+}
+
+func newStallWatchdog(ctx context.Context, idleTimeout time.Duration, minThroughput int64, window time.Duration) *stallWatchdog {
+	if window <= 0 {
+		window = DefaultMinThroughputWindow
+	}
+
+	w := &stallWatchdog{
+		idleTimeout:   idleTimeout,
+		minThroughput: minThroughput,
+		window:        window,
+		done:          make(chan struct{}),
+	}
+
+	w.ctx, w.cancel = context.WithCancelCause(ctx)
+
+	go w.run()
+
+	return w
+}
+
+func (w *stallWatchdog) run() {
+	interval := w.window / 4
+
+	if w.idleTimeout > 0 && (w.minThroughput <= 0 || w.idleTimeout/4 < interval) {
+		interval = w.idleTimeout / 4
+	}
+
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := w.check(now); err != nil {
+				w.cancel(*err)
+				return
+			}
+		}
+	}
+}
+
+func (w *stallWatchdog) check(now time.Time) *StalledTransferError {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.active {
+		return nil
+	}
+
+	if w.idleTimeout > 0 && now.Sub(w.lastActivity) >= w.idleTimeout {
+		return &StalledTransferError{
+			IdleTimeout: w.idleTimeout,
+		}
+	}
+
+	if w.minThroughput > 0 {
+		if elapsed := now.Sub(w.windowStart); elapsed >= w.window {
+			throughput := float64(w.windowTransferred) / elapsed.Seconds()
+
+			if throughput < float64(w.minThroughput) {
+				return &StalledTransferError{
+					Throughput:    throughput,
+					MinThroughput: w.minThroughput,
+					Window:        w.window,
+				}
+			}
+
+			w.windowStart = now
+			w.windowTransferred = 0
+		}
+	}
+
+	return nil
+}
+
+// start starts measuring a body transfer.
+func (w *stallWatchdog) start(response bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if response {
+		w.responding = true
+	}
+
+	if !w.active {
+		now := time.Now()
+
+		w.active = true
+		w.lastActivity = now
+		w.windowStart = now
+		w.windowTransferred = 0
+	}
+}
+
+// pause stops measuring once the request body is sent until the response
+// headers are received.
+func (w *stallWatchdog) pause() {
+	w.mutex.Lock()
+
+	if !w.responding {
+		w.active = false
+	}
+
+	w.mutex.Unlock()
+}
+
+func (w *stallWatchdog) activity(n int) {
+	w.mutex.Lock()
+	w.lastActivity = time.Now()
+	w.windowTransferred += int64(n)
+	w.mutex.Unlock()
+}
+
+// stop stops the watchdog and releases the context once the response body is
+// read or closed.
+func (w *stallWatchdog) stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		w.cancel(nil)
+	})
+}
+
+// wrapErr replaces the error caused by the canceled context with
+// StalledTransferError.
+func (w *stallWatchdog) wrapErr(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+
+	if stalled, ok := context.Cause(w.ctx).(StalledTransferError); ok {
+		return stalled
+	}
+
+	return err
+}
+
+// reader measures the transfer of the request body (the watchdog is paused
+// once it is sent) or the response body (the watchdog is stopped once it is
+// read or closed).
+func (w *stallWatchdog) reader(reader io.ReadCloser, response bool) io.ReadCloser {
+	if response {
+		w.start(true)
+	}
+
+	return &stallReader{
+		reader:   reader,
+		watchdog: w,
+		response: response,
+	}
+}
+
+type stallReader struct {
+	reader   io.ReadCloser
+	watchdog *stallWatchdog
+	response bool
+}
+
+func (r *stallReader) Read(p []byte) (n int, err error) {
+	if !r.response {
+		r.watchdog.start(false)
+	}
+
+	n, err = r.reader.Read(p)
+
+	if n > 0 {
+		r.watchdog.activity(n)
+	}
+
+	if err == io.EOF {
+		if r.response {
+			r.watchdog.stop()
+		} else {
+			r.watchdog.pause()
+		}
+	}
+
+	return n, r.watchdog.wrapErr(err)
+}
+
+func (r *stallReader) Close() error {
+	if r.response {
+		r.watchdog.stop()
+	}
+
+	return r.reader.Close()
+}
@@ -0,0 +1,245 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("Stall detection", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+	var handler func(http.ResponseWriter, *http.Request)
+	var release chan struct{}
+
+	// stall blocks until the client aborts the request
+	stall := func(r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}
+
+	BeforeEach(func() {
+		release = make(chan struct{})
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			handler(w, r)
+		}))
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+	})
+
+	AfterEach(func() {
+		close(release)
+		ts.Close()
+	})
+
+	It("should abort stalled response body", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			stall(r)
+		}
+
+		res, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			IdleTimeout:    100 * time.Millisecond,
+			ExpectedStatus: []int{http.StatusOK},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+
+		_, err = io.ReadAll(res.Body)
+		ste, ok := IsStalledTransferError(err)
+		Expect(ok).To(BeTrue())
+		Expect(ste.IdleTimeout).To(Equal(100 * time.Millisecond))
+	})
+
+	It("should not abort request waiting for response headers", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+			time.Sleep(300 * time.Millisecond)
+			w.Write([]byte("body"))
+		}
+
+		var body []byte
+
+		_, err := client.Request(&RequestData{
+			Method:         "POST",
+			Path:           "/",
+			ReqReader:      strings.NewReader("body"),
+			IdleTimeout:    100 * time.Millisecond,
+			ExpectedStatus: []int{http.StatusOK},
+			RespValue:      &body,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal("body"))
+	})
+
+	It("should abort stalled request body", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			stall(r)
+		}
+
+		_, err := client.Request(&RequestData{
+			Method:      "POST",
+			Path:        "/",
+			ReqReader:   bytes.NewReader(make([]byte, 64*1024*1024)),
+			IdleTimeout: 100 * time.Millisecond,
+		})
+		Expect(err).To(Equal(StalledTransferError{IdleTimeout: 100 * time.Millisecond}))
+	})
+
+	It("should abort transfer below minimum throughput", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			for {
+				if _, err := w.Write([]byte("0123456789")); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+
+				select {
+				case <-r.Context().Done():
+					return
+				case <-release:
+					return
+				case <-time.After(20 * time.Millisecond):
+				}
+			}
+		}
+
+		start := time.Now()
+
+		res, err := client.Request(&RequestData{
+			Method:              "GET",
+			Path:                "/",
+			IdleTimeout:         time.Second,
+			MinThroughput:       10000,
+			MinThroughputWindow: 200 * time.Millisecond,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+
+		_, err = io.ReadAll(res.Body)
+		ste, ok := IsStalledTransferError(err)
+		Expect(ok).To(BeTrue())
+		Expect(ste.IdleTimeout).To(BeZero())
+		Expect(ste.Throughput).To(BeNumerically("<", 10000))
+		Expect(ste.Window).To(Equal(200 * time.Millisecond))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+
+	It("should not abort active transfer", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < 10; i++ {
+				w.Write([]byte("0123456789"))
+				w.(http.Flusher).Flush()
+				time.Sleep(20 * time.Millisecond)
+			}
+		}
+
+		res, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			IdleTimeout:    100 * time.Millisecond,
+			ExpectedStatus: []int{http.StatusOK},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(HaveLen(100))
+	})
+
+	It("should release the request context when the body is done", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.Write([]byte("body"))
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		res, err := client.Request(&RequestData{
+			Context:        ctx,
+			Method:         "GET",
+			Path:           "/",
+			IdleTimeout:    time.Minute,
+			ExpectedStatus: []int{http.StatusOK},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Request.Context().Err()).To(BeNil())
+
+		Expect(io.ReadAll(res.Body)).To(Equal([]byte("body")))
+		Expect(res.Request.Context().Err()).To(Equal(context.Canceled))
+
+		res, err = client.Request(&RequestData{
+			Context:        ctx,
+			Method:         "GET",
+			Path:           "/missing",
+			IdleTimeout:    time.Minute,
+			ExpectedStatus: []int{http.StatusOK},
+		})
+		Expect(IsInvalidStatusCode(err, http.StatusNotFound)).To(BeTrue())
+		Expect(res.Request.Context().Err()).To(Equal(context.Canceled))
+
+		Expect(ctx.Err()).To(BeNil())
+	})
+
+	It("should retry stalled download", func() {
+		var count int32
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&count, 1) == 1 {
+				w.Header().Set("Content-Length", "100")
+				w.Write([]byte("0123456789"))
+				w.(http.Flusher).Flush()
+				stall(r)
+				return
+			}
+
+			w.Write([]byte("0123456789"))
+		}
+
+		file, err := os.Create(filepath.Join(GinkgoT().TempDir(), "download"))
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		n, err := client.Download(&RequestData{
+			Method:      "GET",
+			Path:        "/",
+			IdleTimeout: 100 * time.Millisecond,
+		}, file, &DownloadOptions{
+			MaxRetries:   1,
+			RetryBackoff: time.Millisecond,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(int64(10)))
+		Expect(int(count)).To(Equal(2))
+	})
+})
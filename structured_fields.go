@@ -0,0 +1,328 @@
+package httpclient
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Structured Field Values (RFC 8941), only the parts used by message
+// signatures. Bare item values are string, sfToken, int64, []byte or bool.
+
+type sfToken string
+
+type sfParam struct {
+	key   string
+	value interface{}
+}
+
+type sfItem struct {
+	value  interface{}
+	params []sfParam
+}
+
+type sfMember struct {
+	key    string
+	isList bool
+	list   []sfItem // inner list items if isList
+	item   sfItem   // the item or the inner list parameters
+	raw    string   // the serialized member value as received
+}
+
+type sfParser struct {
+	s string
+}
+
+func (p *sfParser) skipSpace() {
+	p.s = strings.TrimLeft(p.s, " \t")
+}
+
+func (p *sfParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("HTTPClient: invalid structured field: "+format, a...)
+}
+
+func isSFKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_' || c == '-' || c == '.' || c == '*'
+}
+
+func (p *sfParser) parseKey() (key string, err error) {
+	if p.s == "" || !((p.s[0] >= 'a' && p.s[0] <= 'z') || p.s[0] == '*') {
+		return "", p.errorf("expected key")
+	}
+
+	i := 1
+
+	for i < len(p.s) && isSFKeyChar(p.s[i]) {
+		i++
+	}
+
+	key = p.s[:i]
+	p.s = p.s[i:]
+
+	return key, nil
+}
+
+func (p *sfParser) parseBareItem() (value interface{}, err error) {
+	if p.s == "" {
+		return nil, p.errorf("expected item")
+	}
+
+	c := p.s[0]
+
+	switch {
+	case c == '"':
+		var b strings.Builder
+
+		for i := 1; i < len(p.s); i++ {
+			switch p.s[i] {
+			case '\\':
+				i++
+
+				if i == len(p.s) || (p.s[i] != '"' && p.s[i] != '\\') {
+					return nil, p.errorf("invalid escape")
+				}
+
+				b.WriteByte(p.s[i])
+
+			case '"':
+				p.s = p.s[i+1:]
+				return b.String(), nil
+
+			default:
+				b.WriteByte(p.s[i])
+			}
+		}
+
+		return nil, p.errorf("unterminated string")
+
+	case c == ':':
+		end := strings.IndexByte(p.s[1:], ':')
+
+		if end < 0 {
+			return nil, p.errorf("unterminated byte sequence")
+		}
+
+		b, err := base64.StdEncoding.DecodeString(p.s[1 : end+1])
+
+		if err != nil {
+			return nil, p.errorf("invalid byte sequence")
+		}
+
+		p.s = p.s[end+2:]
+
+		return b, nil
+
+	case c == '?':
+		if len(p.s) < 2 || (p.s[1] != '0' && p.s[1] != '1') {
+			return nil, p.errorf("invalid boolean")
+		}
+
+		value = p.s[1] == '1'
+		p.s = p.s[2:]
+
+		return value, nil
+
+	case c == '-' || (c >= '0' && c <= '9'):
+		i := 1
+
+		for i < len(p.s) && p.s[i] >= '0' && p.s[i] <= '9' {
+			i++
+		}
+
+		n, err := strconv.ParseInt(p.s[:i], 10, 64)
+
+		if err != nil {
+			return nil, p.errorf("invalid integer")
+		}
+
+		p.s = p.s[i:]
+
+		return n, nil
+
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '*':
+		i := strings.IndexAny(p.s, " \t,;()=\"")
+
+		if i < 0 {
+			i = len(p.s)
+		}
+
+		token := sfToken(p.s[:i])
+		p.s = p.s[i:]
+
+		return token, nil
+	}
+
+	return nil, p.errorf("unexpected %q", c)
+}
+
+func (p *sfParser) parseParams() (params []sfParam, err error) {
+	for strings.HasPrefix(p.s, ";") {
+		p.s = p.s[1:]
+		p.skipSpace()
+
+		key, err := p.parseKey()
+
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{} = true
+
+		if strings.HasPrefix(p.s, "=") {
+			p.s = p.s[1:]
+
+			if value, err = p.parseBareItem(); err != nil {
+				return nil, err
+			}
+		}
+
+		params = append(params, sfParam{key, value})
+	}
+
+	return params, nil
+}
+
+func (p *sfParser) parseItem() (item sfItem, err error) {
+	if item.value, err = p.parseBareItem(); err != nil {
+		return sfItem{}, err
+	}
+
+	if item.params, err = p.parseParams(); err != nil {
+		return sfItem{}, err
+	}
+
+	return item, nil
+}
+
+func (p *sfParser) parseInnerList() (list []sfItem, params []sfParam, err error) {
+	// skip the opening parenthesis
+	p.s = p.s[1:]
+
+	for {
+		p.skipSpace()
+
+		if strings.HasPrefix(p.s, ")") {
+			p.s = p.s[1:]
+
+			params, err = p.parseParams()
+
+			return list, params, err
+		}
+
+		item, err := p.parseItem()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		list = append(list, item)
+
+		if p.s != "" && p.s[0] != ' ' && p.s[0] != ')' {
+			return nil, nil, p.errorf("invalid inner list")
+		}
+	}
+}
+
+func parseSFDictionary(s string) (members []sfMember, err error) {
+	p := &sfParser{s: strings.TrimSpace(s)}
+
+	for p.s != "" {
+		member := sfMember{}
+
+		if member.key, err = p.parseKey(); err != nil {
+			return nil, err
+		}
+
+		if strings.HasPrefix(p.s, "=") {
+			p.s = p.s[1:]
+
+			start := p.s
+
+			if strings.HasPrefix(p.s, "(") {
+				member.isList = true
+
+				if member.list, member.item.params, err = p.parseInnerList(); err != nil {
+					return nil, err
+				}
+			} else if member.item, err = p.parseItem(); err != nil {
+				return nil, err
+			}
+
+			member.raw = start[:len(start)-len(p.s)]
+		} else {
+			member.item.value = true
+
+			if member.item.params, err = p.parseParams(); err != nil {
+				return nil, err
+			}
+		}
+
+		members = append(members, member)
+
+		p.skipSpace()
+
+		if p.s == "" {
+			break
+		}
+
+		if !strings.HasPrefix(p.s, ",") {
+			return nil, p.errorf("expected comma")
+		}
+
+		p.s = p.s[1:]
+		p.skipSpace()
+	}
+
+	return members, nil
+}
+
+func serializeSFBareItem(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	case sfToken:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case []byte:
+		return ":" + base64.StdEncoding.EncodeToString(v) + ":"
+	case bool:
+		if v {
+			return "?1"
+		}
+		return "?0"
+	}
+
+	return ""
+}
+
+func serializeSFParams(params []sfParam) string {
+	var b strings.Builder
+
+	for _, param := range params {
+		b.WriteString(";")
+		b.WriteString(param.key)
+
+		if param.value != true {
+			b.WriteString("=")
+			b.WriteString(serializeSFBareItem(param.value))
+		}
+	}
+
+	return b.String()
+}
+
+func serializeSFItem(item sfItem) string {
+	return serializeSFBareItem(item.value) + serializeSFParams(item.params)
+}
+
+func serializeSFInnerList(list []sfItem, params []sfParam) string {
+	items := make([]string, len(list))
+
+	for i, item := range list {
+		items[i] = serializeSFItem(item)
+	}
+
+	return "(" + strings.Join(items, " ") + ")" + serializeSFParams(params)
+}
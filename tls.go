@@ -0,0 +1,373 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultTLSReloadInterval is how often the certificate files are checked for
+// changes.
+var DefaultTLSReloadInterval = 10 * time.Second
+
+// TLSOptions configures TLS for an HTTPClient, see HTTPClient.SetTLS.
+// Certificates and root CAs loaded from files are reloaded when the files
+// change.
+type TLSOptions struct {
+	CertFile    string // client certificate (PEM)
+	KeyFile     string
+	CertPEM     []byte
+	KeyPEM      []byte
+	RootCAFile  string // PEM bundle, replaces the system root CAs
+	RootCAPEM   []byte
+	SystemRoots bool // add the root CAs to the system root CAs
+	ServerName  string
+	MinVersion  uint16
+
+	InsecureSkipVerify bool
+
+	// Pins are public key pins (see PublicKeyPin), optionally prefixed with
+	// sha256/. A certificate of the chain must match one of Pins or
+	// BackupPins. With InsecureSkipVerify only the leaf certificate is
+	// checked, e.g. for self-signed certificates.
+	Pins          []string
+	BackupPins    []string
+	PinReportOnly bool               // only call OnPinFailure
+	OnPinFailure  func(PinningError) // can be nil
+
+	ReloadInterval time.Duration // defaults to DefaultTLSReloadInterval
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// fileReloader caches a value loaded from files and loads it again when the
+// files change. The files are checked at most once per interval. If loading
+// fails the previous value is kept, e.g. while a certificate is written
+// before its key.
+type fileReloader struct {
+	files    []string
+	interval time.Duration
+	load     func() (interface{}, error)
+
+	mutex   sync.Mutex
+	value   interface{}
+	stamps  []fileStamp
+	checked time.Time
+}
+
+func newFileReloader(files []string, interval time.Duration, load func() (interface{}, error)) (r *fileReloader, err error) {
+	if interval <= 0 {
+		interval = DefaultTLSReloadInterval
+	}
+
+	r = &fileReloader{
+		files:    files,
+		interval: interval,
+		load:     load,
+	}
+
+	// fail early if the files cannot be loaded
+	if _, _, err = r.get(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *fileReloader) statFiles() (stamps []fileStamp, err error) {
+	stamps = make([]fileStamp, len(r.files))
+
+	for i, file := range r.files {
+		info, err := os.Stat(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		stamps[i] = fileStamp{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		}
+	}
+
+	return stamps, nil
+}
+
+func (r *fileReloader) get() (value interface{}, changed bool, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+
+	if r.value != nil && now.Sub(r.checked) < r.interval {
+		return r.value, false, nil
+	}
+
+	r.checked = now
+
+	stamps, err := r.statFiles()
+
+	if err == nil && r.value != nil && equalFileStamps(stamps, r.stamps) {
+		return r.value, false, nil
+	}
+
+	if err == nil {
+		value, err = r.load()
+	}
+
+	if err != nil {
+		if r.value != nil {
+			return r.value, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	r.value = value
+	r.stamps = stamps
+
+	return value, true, nil
+}
+
+func equalFileStamps(a []fileStamp, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (o *TLSOptions) certPool(pem []byte) (pool *x509.CertPool, err error) {
+	if o.SystemRoots {
+		if pool, err = x509.SystemCertPool(); err != nil {
+			return nil, err
+		}
+	} else {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("HTTPClient: no root CA certificates found")
+	}
+
+	return pool, nil
+}
+
+// tlsConfig returns the TLS config and the root CA reloader if the root CAs
+// are loaded from RootCAFile.
+func (o *TLSOptions) tlsConfig() (config *tls.Config, roots *fileReloader, err error) {
+	config = &tls.Config{
+		ServerName:         o.ServerName,
+		MinVersion:         o.MinVersion,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if len(o.Pins) > 0 || len(o.BackupPins) > 0 {
+		config.VerifyConnection = pinVerifier(o)
+	}
+
+	switch {
+	case o.CertFile != "" || o.KeyFile != "":
+		certs, err := newFileReloader([]string{o.CertFile, o.KeyFile}, o.ReloadInterval, func() (interface{}, error) {
+			cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+			return &cert, err
+		})
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _, err := certs.get()
+
+			if err != nil {
+				return nil, err
+			}
+
+			return cert.(*tls.Certificate), nil
+		}
+
+	case o.CertPEM != nil || o.KeyPEM != nil:
+		cert, err := tls.X509KeyPair(o.CertPEM, o.KeyPEM)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	switch {
+	case o.RootCAFile != "":
+		roots, err = newFileReloader([]string{o.RootCAFile}, o.ReloadInterval, func() (interface{}, error) {
+			pem, err := os.ReadFile(o.RootCAFile)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return o.certPool(pem)
+		})
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+	case o.RootCAPEM != nil:
+		if config.RootCAs, err = o.certPool(o.RootCAPEM); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return config, roots, nil
+}
+
+// rootsReloadingTransport creates a new transport when the root CAs change
+// because the root CAs of a transport cannot be changed.
+type rootsReloadingTransport struct {
+	config       *tls.Config
+	roots        *fileReloader
+	newTransport func(config *tls.Config) (*http.Transport, error)
+
+	mutex     sync.Mutex
+	transport *http.Transport
+}
+
+func (t *rootsReloadingTransport) current() (transport *http.Transport, err error) {
+	roots, changed, err := t.roots.get()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if changed || t.transport == nil {
+		config := t.config.Clone()
+		config.RootCAs = roots.(*x509.CertPool)
+
+		transport, err := t.newTransport(config)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if t.transport != nil {
+			t.transport.CloseIdleConnections()
+		}
+
+		t.transport = transport
+	}
+
+	return t.transport, nil
+}
+
+func (t *rootsReloadingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := t.current()
+
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	return transport.RoundTrip(req)
+}
+
+func (t *rootsReloadingTransport) CloseIdleConnections() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.transport != nil {
+		t.transport.CloseIdleConnections()
+	}
+}
+
+// tlsTransport returns a transport created by newTransport with the TLS
+// options.
+func tlsTransport(options *TLSOptions, newTransport func(config *tls.Config) (*http.Transport, error)) (http.RoundTripper, error) {
+	config, roots, err := options.tlsConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if roots != nil {
+		transport := &rootsReloadingTransport{
+			config:       config,
+			roots:        roots,
+			newTransport: newTransport,
+		}
+
+		// fail early if the transport cannot be created
+		if _, err = transport.current(); err != nil {
+			return nil, err
+		}
+
+		return transport, nil
+	}
+
+	return newTransport(config)
+}
+
+// SetTLS sets the TLS options of the client's transport options (see
+// SetTransport) or replaces the client's http.Client with one that uses a
+// copy of the current transport with the TLS options. The global
+// HttpTransport is not changed.
+func (c *HTTPClient) SetTLS(options TLSOptions) error {
+	if c.transportOptions != nil {
+		transportOptions := *c.transportOptions
+		transportOptions.TLS = &options
+
+		return c.SetTransport(transportOptions)
+	}
+
+	var base *http.Transport
+
+	switch t := c.Client.Transport.(type) {
+	case *http.Transport:
+		base = t.Clone()
+	case *rootsReloadingTransport:
+		current, err := t.current()
+
+		if err != nil {
+			return err
+		}
+
+		base = current.Clone()
+	case nil:
+		base = http.DefaultTransport.(*http.Transport).Clone()
+	default:
+		return fmt.Errorf("HTTPClient: cannot set TLS options for transport %T", t)
+	}
+
+	transport, err := tlsTransport(&options, func(config *tls.Config) (*http.Transport, error) {
+		t := base.Clone()
+		t.TLSClientConfig = config
+		return t, nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	c.setTransport(transport)
+
+	return nil
+}
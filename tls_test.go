@@ -0,0 +1,225 @@
+package httpclient_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	Expect(err).NotTo(HaveOccurred())
+	return cert
+}
+
+// newTestCert creates a certificate signed by parent or a self-signed CA if
+// parent is nil.
+func newTestCert(commonName string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{commonName},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signerCert, signerKey := template, key
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	Expect(err).NotTo(HaveOccurred())
+
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+var _ = Describe("TLS", func() {
+	var ca *testCert
+	var ts *httptest.Server
+	var dir string
+	var client *HTTPClient
+
+	writeFile := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		Expect(os.WriteFile(path, data, 0600)).To(Succeed())
+		// make sure the change is detected even with a coarse mtime
+		later := time.Now().Add(time.Duration(len(data)) * time.Millisecond)
+		Expect(os.Chtimes(path, later, later)).To(Succeed())
+		return path
+	}
+
+	startServer := func(serverCA *testCert) {
+		ts = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.TLS.PeerCertificates) > 0 {
+				w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+			}
+		}))
+
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(ca.cert)
+
+		ts.TLS = &tls.Config{
+			Certificates: []tls.Certificate{newTestCert("server", serverCA).tlsCertificate()},
+			ClientAuth:   tls.VerifyClientCertIfGiven,
+			ClientCAs:    clientCAs,
+		}
+
+		ts.StartTLS()
+
+		u, _ := url.Parse(ts.URL)
+		client.BaseURL = u
+	}
+
+	BeforeEach(func() {
+		ca = newTestCert("ca", nil)
+		dir = GinkgoT().TempDir()
+
+		client = New()
+	})
+
+	AfterEach(func() {
+		if ts != nil {
+			ts.Close()
+			ts = nil
+		}
+	})
+
+	get := func() (string, error) {
+		var body []byte
+
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			Headers:        http.Header{"Connection": {"close"}},
+			ExpectedStatus: []int{http.StatusOK},
+			RespValue:      &body,
+		})
+
+		return string(body), err
+	}
+
+	It("should use client certificate and root CA from PEM bytes", func() {
+		startServer(ca)
+
+		clientCert := newTestCert("client", ca)
+
+		Expect(client.SetTLS(TLSOptions{
+			CertPEM:   clientCert.certPEM,
+			KeyPEM:    clientCert.keyPEM,
+			RootCAPEM: ca.certPEM,
+		})).To(Succeed())
+
+		Expect(get()).To(Equal("client"))
+
+		Expect(client.Client).NotTo(BeIdenticalTo(HttpClient))
+		Expect(client.Client.Transport).NotTo(BeIdenticalTo(HttpTransport))
+	})
+
+	It("should reload client certificate from files", func() {
+		startServer(ca)
+
+		clientCert := newTestCert("client1", ca)
+
+		options := TLSOptions{
+			CertFile:       writeFile("client.crt", clientCert.certPEM),
+			KeyFile:        writeFile("client.key", clientCert.keyPEM),
+			RootCAPEM:      ca.certPEM,
+			ReloadInterval: time.Millisecond,
+		}
+
+		Expect(client.SetTLS(options)).To(Succeed())
+
+		Expect(get()).To(Equal("client1"))
+
+		clientCert = newTestCert("client2", ca)
+
+		// the certificate does not match the key until the key is written
+		writeFile("client.crt", clientCert.certPEM)
+		time.Sleep(5 * time.Millisecond)
+		Expect(get()).To(Equal("client1"))
+
+		writeFile("client.key", clientCert.keyPEM)
+		time.Sleep(5 * time.Millisecond)
+		Expect(get()).To(Equal("client2"))
+	})
+
+	It("should reload root CAs from file", func() {
+		otherCA := newTestCert("other", nil)
+
+		startServer(otherCA)
+
+		Expect(client.SetTLS(TLSOptions{
+			RootCAFile:     writeFile("ca.crt", ca.certPEM),
+			ReloadInterval: time.Millisecond,
+		})).To(Succeed())
+
+		_, err := get()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("certificate"))
+
+		writeFile("ca.crt", append(append([]byte{}, ca.certPEM...), otherCA.certPEM...))
+		time.Sleep(5 * time.Millisecond)
+
+		Expect(get()).To(Equal(""))
+	})
+
+	It("should fail for invalid files", func() {
+		Expect(client.SetTLS(TLSOptions{
+			CertFile: filepath.Join(dir, "missing.crt"),
+			KeyFile:  filepath.Join(dir, "missing.key"),
+		})).To(MatchError(os.ErrNotExist))
+
+		Expect(client.SetTLS(TLSOptions{
+			RootCAPEM: []byte("invalid"),
+		})).To(MatchError("HTTPClient: no root CA certificates found"))
+	})
+})
@@ -1,13 +1,23 @@
 package httpclient
 
 import (
+	"context"
 	"crypto/tls"
+	"net"
 	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http2"
 )
 
 var HttpTransport = &http.Transport{
-	DisableCompression: true,
-	Proxy:              http.ProxyFromEnvironment,
+	DisableCompression:    true,
+	Proxy:                 unixSocketProxy(http.ProxyFromEnvironment),
+	DialContext:           DialContext,
+	ExpectContinueTimeout: 1 * time.Second,
+	// HTTP/2 is only enabled by default without a custom DialContext
+	ForceAttemptHTTP2: true,
 }
 
 var HttpClient = &http.Client{
@@ -19,11 +29,191 @@ var InsecureTlsConfig = &tls.Config{
 }
 
 var InsecureHttpTransport = &http.Transport{
-	TLSClientConfig:    InsecureTlsConfig,
-	DisableCompression: true,
-	Proxy:              http.ProxyFromEnvironment,
+	TLSClientConfig:       InsecureTlsConfig,
+	DisableCompression:    true,
+	Proxy:                 unixSocketProxy(http.ProxyFromEnvironment),
+	DialContext:           DialContext,
+	ExpectContinueTimeout: 1 * time.Second,
 }
 
 var InsecureHttpClient = &http.Client{
 	Transport: InsecureHttpTransport,
 }
+
+// TransportOptions configures a transport for a single HTTPClient, see
+// NewTransport and HTTPClient.SetTransport. Zero values use the defaults of
+// http.DefaultTransport.
+type TransportOptions struct {
+	DialTimeout           time.Duration // defaults to 30s
+	KeepAlive             time.Duration // defaults to 30s
+	TLSHandshakeTimeout   time.Duration // defaults to 10s
+	ResponseHeaderTimeout time.Duration
+	ExpectContinueTimeout time.Duration // defaults to 1s
+	IdleConnTimeout       time.Duration // defaults to 90s
+	MaxIdleConns          int           // defaults to 100
+	MaxIdleConnsPerHost   int           // defaults to http.DefaultMaxIdleConnsPerHost
+	MaxConnsPerHost       int
+	DisableKeepAlives     bool
+
+	DisableHTTP2 bool
+
+	// HTTP2ReadIdleTimeout enables health check pings if no frame was received
+	// for the timeout. The connection is closed if the ping is not answered
+	// within HTTP2PingTimeout (defaults to 15s).
+	HTTP2ReadIdleTimeout            time.Duration
+	HTTP2PingTimeout                time.Duration
+	HTTP2StrictMaxConcurrentStreams bool
+
+	Proxy        func(*http.Request) (*url.URL, error) // defaults to http.ProxyFromEnvironment
+	DisableProxy bool
+
+	// DialContext dials TCP connections instead of a net.Dialer with
+	// DialTimeout and KeepAlive. Unix socket base URLs are always supported.
+	DialContext func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+	// Resolve overrides addresses like curl --resolve, e.g.
+	// "api.example.com:443" to "10.0.0.1:443". Keys and values without a port
+	// apply to all ports. TLS server name and Host header are not changed.
+	Resolve map[string]string
+
+	Resolver    HostResolver  // defaults to the resolver of the dialer
+	DNSCacheTTL time.Duration // caches resolved addresses, see DNSCache
+
+	TLS *TLSOptions
+}
+
+func durationOrDefault(d time.Duration, defaultDuration time.Duration) time.Duration {
+	if d == 0 {
+		return defaultDuration
+	}
+
+	return d
+}
+
+func (o *TransportOptions) newHTTPTransport(tlsConfig *tls.Config) (t *http.Transport, err error) {
+	dialer := &net.Dialer{
+		Timeout:   durationOrDefault(o.DialTimeout, 30*time.Second),
+		KeepAlive: durationOrDefault(o.KeepAlive, 30*time.Second),
+	}
+
+	maxIdleConns := o.MaxIdleConns
+
+	if maxIdleConns == 0 {
+		maxIdleConns = 100
+	}
+
+	proxy := o.Proxy
+
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+
+	proxy = unixSocketProxy(proxy)
+
+	if o.DisableProxy {
+		proxy = nil
+	}
+
+	dial := o.DialContext
+
+	if dial == nil {
+		dial = dialer.DialContext
+	}
+
+	resolver := o.Resolver
+
+	if o.DNSCacheTTL > 0 {
+		resolver = NewDNSCache(resolver, o.DNSCacheTTL)
+	}
+
+	if len(o.Resolve) > 0 || resolver != nil {
+		dial = resolvingDialContext(dial, o.Resolve, resolver)
+	}
+
+	t = &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           unixDialContext(dial),
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   durationOrDefault(o.TLSHandshakeTimeout, 10*time.Second),
+		ResponseHeaderTimeout: o.ResponseHeaderTimeout,
+		ExpectContinueTimeout: durationOrDefault(o.ExpectContinueTimeout, 1*time.Second),
+		IdleConnTimeout:       durationOrDefault(o.IdleConnTimeout, 90*time.Second),
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   o.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       o.MaxConnsPerHost,
+		DisableKeepAlives:     o.DisableKeepAlives,
+		DisableCompression:    true,
+	}
+
+	switch {
+	case o.DisableHTTP2:
+		// a non-nil empty map disables HTTP/2
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+
+	case o.HTTP2ReadIdleTimeout > 0 || o.HTTP2PingTimeout > 0 || o.HTTP2StrictMaxConcurrentStreams:
+		h2, err := http2.ConfigureTransports(t)
+
+		if err != nil {
+			return nil, err
+		}
+
+		h2.ReadIdleTimeout = o.HTTP2ReadIdleTimeout
+		h2.PingTimeout = o.HTTP2PingTimeout
+		h2.StrictMaxConcurrentStreams = o.HTTP2StrictMaxConcurrentStreams
+
+	default:
+		t.ForceAttemptHTTP2 = true
+	}
+
+	return t, nil
+}
+
+// NewTransport creates a new transport. It does not share connections or
+// settings with other transports.
+func NewTransport(options TransportOptions) (http.RoundTripper, error) {
+	if options.TLS == nil {
+		return options.newHTTPTransport(nil)
+	}
+
+	return tlsTransport(options.TLS, options.newHTTPTransport)
+}
+
+// NewWithTransport creates a client with its own transport.
+func NewWithTransport(options TransportOptions) (httpClient *HTTPClient, err error) {
+	httpClient = New()
+
+	if err = httpClient.SetTransport(options); err != nil {
+		return nil, err
+	}
+
+	return httpClient, nil
+}
+
+// SetTransport replaces the client's http.Client with one that uses a new
+// transport. Client.CheckRedirect, Jar and Timeout are kept.
+func (c *HTTPClient) SetTransport(options TransportOptions) error {
+	transport, err := NewTransport(options)
+
+	if err != nil {
+		return err
+	}
+
+	c.setTransport(transport)
+	c.transportOptions = &options
+
+	return nil
+}
+
+func (c *HTTPClient) setTransport(transport http.RoundTripper) {
+	client := &http.Client{
+		Transport: transport,
+	}
+
+	if c.Client != nil {
+		client.CheckRedirect = c.Client.CheckRedirect
+		client.Jar = c.Client.Jar
+		client.Timeout = c.Client.Timeout
+	}
+
+	c.Client = client
+}
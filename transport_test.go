@@ -0,0 +1,150 @@
+package httpclient_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("TransportOptions", func() {
+	var ts *httptest.Server
+	var client *HTTPClient
+
+	serverPEM := func() []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	}
+
+	get := func() (proto string, err error) {
+		var body []byte
+
+		_, err = client.Request(&RequestData{
+			Method:         "GET",
+			Path:           "/",
+			ExpectedStatus: []int{http.StatusOK},
+			RespValue:      &body,
+		})
+
+		return string(body), err
+	}
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	startTLS := func() {
+		ts = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.Proto)
+		}))
+		ts.EnableHTTP2 = true
+		ts.StartTLS()
+	}
+
+	newClient := func(options TransportOptions) {
+		var err error
+
+		client, err = NewWithTransport(options)
+		Expect(err).NotTo(HaveOccurred())
+
+		client.BaseURL, _ = url.Parse(ts.URL)
+	}
+
+	It("should create a separate transport", func() {
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.Proto)
+		}))
+
+		newClient(TransportOptions{
+			MaxConnsPerHost:     2,
+			MaxIdleConnsPerHost: 3,
+			IdleConnTimeout:     time.Minute,
+		})
+
+		Expect(get()).To(Equal("HTTP/1.1"))
+
+		transport := client.Client.Transport.(*http.Transport)
+		Expect(transport).NotTo(BeIdenticalTo(HttpTransport))
+		Expect(transport.MaxConnsPerHost).To(Equal(2))
+		Expect(transport.MaxIdleConnsPerHost).To(Equal(3))
+		Expect(transport.IdleConnTimeout).To(Equal(time.Minute))
+		Expect(transport.TLSHandshakeTimeout).To(Equal(10 * time.Second))
+		Expect(transport.DisableCompression).To(BeTrue())
+	})
+
+	It("should time out waiting for response headers", func() {
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+
+		newClient(TransportOptions{
+			ResponseHeaderTimeout: 20 * time.Millisecond,
+		})
+
+		_, err := get()
+		Expect(err).To(MatchError(ContainSubstring("timeout awaiting response headers")))
+	})
+
+	It("should use a proxy", func() {
+		var proxied string
+
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.RequestURI
+		}))
+
+		proxyURL, _ := url.Parse(ts.URL)
+
+		newClient(TransportOptions{
+			Proxy: http.ProxyURL(proxyURL),
+		})
+
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			FullURL:        "http://example.invalid/",
+			ExpectedStatus: []int{http.StatusOK},
+			RespConsume:    true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(proxied).To(Equal("http://example.invalid/"))
+	})
+
+	It("should use HTTP/2 unless disabled", func() {
+		startTLS()
+
+		newClient(TransportOptions{
+			TLS: &TLSOptions{RootCAPEM: serverPEM()},
+		})
+		Expect(get()).To(Equal("HTTP/2.0"))
+
+		newClient(TransportOptions{
+			TLS:                  &TLSOptions{RootCAPEM: serverPEM()},
+			HTTP2ReadIdleTimeout: time.Second,
+		})
+		Expect(get()).To(Equal("HTTP/2.0"))
+
+		newClient(TransportOptions{
+			TLS:          &TLSOptions{RootCAPEM: serverPEM()},
+			DisableHTTP2: true,
+		})
+		Expect(get()).To(Equal("HTTP/1.1"))
+	})
+
+	It("should keep transport options when TLS is set", func() {
+		startTLS()
+
+		newClient(TransportOptions{
+			DisableHTTP2:    true,
+			MaxConnsPerHost: 5,
+		})
+
+		Expect(client.SetTLS(TLSOptions{RootCAPEM: serverPEM()})).To(Succeed())
+		Expect(get()).To(Equal("HTTP/1.1"))
+		Expect(client.Client.Transport.(*http.Transport).MaxConnsPerHost).To(Equal(5))
+	})
+})
@@ -0,0 +1,490 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const TusVersion = "1.0.0"
+
+const tusStatusChecksumMismatch = 460
+
+var tusChecksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// TusUpload is the state of a tus upload. It can be serialized to JSON to
+// resume the upload after a restart.
+type TusUpload struct {
+	URL         string            `json:"url"`
+	Size        int64             `json:"size"`
+	Offset      int64             `json:"offset"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+}
+
+type TusStore interface {
+	Get(fingerprint string) (upload *TusUpload, ok bool, err error)
+	Set(upload *TusUpload) error
+	Delete(fingerprint string) error
+}
+
+type TusMemoryStore struct {
+	mutex   sync.Mutex
+	uploads map[string]TusUpload
+}
+
+func NewTusMemoryStore() *TusMemoryStore {
+	return &TusMemoryStore{
+		uploads: make(map[string]TusUpload),
+	}
+}
+
+func (s *TusMemoryStore) Get(fingerprint string) (upload *TusUpload, ok bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	u, ok := s.uploads[fingerprint]
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	return &u, true, nil
+}
+
+func (s *TusMemoryStore) Set(upload *TusUpload) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.uploads[upload.Fingerprint] = *upload
+
+	return nil
+}
+
+func (s *TusMemoryStore) Delete(fingerprint string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.uploads, fingerprint)
+
+	return nil
+}
+
+// TusFileStore keeps each upload in a JSON file in Dir.
+type TusFileStore struct {
+	Dir string
+}
+
+func NewTusFileStore(dir string) *TusFileStore {
+	return &TusFileStore{
+		Dir: dir,
+	}
+}
+
+func (s *TusFileStore) path(fingerprint string) string {
+	sum := sha256.Sum256([]byte(fingerprint))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *TusFileStore) Get(fingerprint string) (upload *TusUpload, ok bool, err error) {
+	data, err := os.ReadFile(s.path(fingerprint))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	upload = &TusUpload{}
+
+	if err = json.Unmarshal(data, upload); err != nil {
+		return nil, false, err
+	}
+
+	return upload, true, nil
+}
+
+func (s *TusFileStore) Set(upload *TusUpload) (err error) {
+	data, err := json.Marshal(upload)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(s.Dir, ".tus-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path(upload.Fingerprint))
+}
+
+func (s *TusFileStore) Delete(fingerprint string) error {
+	err := os.Remove(s.path(fingerprint))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+type TusClient struct {
+	Client            *HTTPClient
+	Path              string // creation endpoint, relative to Client.BaseURL
+	ChunkSize         int64
+	ChecksumAlgorithm string // checksum extension algorithm, e.g. sha1
+	Store             TusStore
+	MaxRetries        int
+	RetryBackoff      time.Duration
+	MaxRetryBackoff   time.Duration
+}
+
+func NewTusClient(client *HTTPClient, path string) *TusClient {
+	return &TusClient{
+		Client:          client,
+		Path:            path,
+		ChunkSize:       8 * 1024 * 1024,
+		MaxRetries:      5,
+		RetryBackoff:    1 * time.Second,
+		MaxRetryBackoff: 30 * time.Second,
+	}
+}
+
+func encodeTusMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+
+	for i, k := range keys {
+		if v := metadata[k]; v != "" {
+			pairs[i] = k + " " + base64.StdEncoding.EncodeToString([]byte(v))
+		} else {
+			pairs[i] = k
+		}
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func parseTusOffset(res *http.Response) (offset int64, err error) {
+	offset, err = strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64)
+
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("HTTPClient: invalid Upload-Offset: %s", res.Header.Get("Upload-Offset"))
+	}
+
+	return offset, nil
+}
+
+func (t *TusClient) headers() http.Header {
+	headers := make(http.Header)
+	headers.Set("Tus-Resumable", TusVersion)
+	return headers
+}
+
+func (t *TusClient) save(upload *TusUpload) error {
+	if t.Store == nil || upload.Fingerprint == "" {
+		return nil
+	}
+
+	return t.Store.Set(upload)
+}
+
+func (t *TusClient) Create(ctx context.Context, size int64, metadata map[string]string) (upload *TusUpload, err error) {
+	headers := t.headers()
+	headers.Set("Upload-Length", strconv.FormatInt(size, 10))
+
+	if len(metadata) > 0 {
+		headers.Set("Upload-Metadata", encodeTusMetadata(metadata))
+	}
+
+	res, err := t.Client.Request(&RequestData{
+		Context:        ctx,
+		Method:         "POST",
+		Path:           t.Path,
+		Headers:        headers,
+		ExpectedStatus: []int{http.StatusCreated},
+		RespConsume:    true,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := url.Parse(res.Header.Get("Location"))
+
+	if err != nil || res.Header.Get("Location") == "" {
+		return nil, fmt.Errorf("HTTPClient: invalid tus Location: %s", res.Header.Get("Location"))
+	}
+
+	base, err := parseRequestURL(res.Request.URL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &TusUpload{
+		URL:      base.ResolveReference(location).String(),
+		Size:     size,
+		Metadata: metadata,
+	}, nil
+}
+
+// Offset fetches the current offset of the upload from the server.
+func (t *TusClient) Offset(ctx context.Context, upload *TusUpload) (offset int64, err error) {
+	headers := t.headers()
+	headers.Set("Cache-Control", "no-store")
+
+	res, err := t.Client.Request(&RequestData{
+		Context:        ctx,
+		Method:         "HEAD",
+		FullURL:        upload.URL,
+		Headers:        headers,
+		ExpectedStatus: []int{http.StatusOK, http.StatusNoContent},
+		RespConsume:    true,
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if offset, err = parseTusOffset(res); err != nil {
+		return 0, err
+	}
+
+	upload.Offset = offset
+
+	return offset, nil
+}
+
+func (t *TusClient) checksum(r io.ReaderAt, offset int64, size int64) (header string, err error) {
+	newHash, ok := tusChecksumAlgorithms[t.ChecksumAlgorithm]
+
+	if !ok {
+		return "", fmt.Errorf("HTTPClient: invalid ChecksumAlgorithm: %s", t.ChecksumAlgorithm)
+	}
+
+	h := newHash()
+
+	if _, err = io.Copy(h, io.NewSectionReader(r, offset, size)); err != nil {
+		return "", err
+	}
+
+	return t.ChecksumAlgorithm + " " + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func (t *TusClient) patch(ctx context.Context, upload *TusUpload, r io.ReaderAt) (err error) {
+	size := upload.Size - upload.Offset
+
+	if t.ChunkSize > 0 && size > t.ChunkSize {
+		size = t.ChunkSize
+	}
+
+	headers := t.headers()
+	headers.Set("Content-Type", "application/offset+octet-stream")
+	headers.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+
+	if t.ChecksumAlgorithm != "" {
+		checksum, err := t.checksum(r, upload.Offset, size)
+
+		if err != nil {
+			return err
+		}
+
+		headers.Set("Upload-Checksum", checksum)
+	}
+
+	res, err := t.Client.Request(&RequestData{
+		Context:          ctx,
+		Method:           "PATCH",
+		FullURL:          upload.URL,
+		Headers:          headers,
+		ReqReader:        io.NewSectionReader(r, upload.Offset, size),
+		ReqContentLength: size,
+		ReqCompression:   CompressionNone, // the server stores the body as is
+		ExpectedStatus:   []int{http.StatusNoContent},
+		RespConsume:      true,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	offset, err := parseTusOffset(res)
+
+	if err != nil {
+		return err
+	}
+
+	if offset <= upload.Offset || offset > upload.Size {
+		return fmt.Errorf("HTTPClient: unexpected Upload-Offset %d after %d", offset, upload.Offset)
+	}
+
+	upload.Offset = offset
+
+	return nil
+}
+
+func (t *TusClient) isRetryable(ctx context.Context, err error) bool {
+	// conflicting offsets and checksum mismatches are resolved by fetching the
+	// offset again
+	if IsInvalidStatusCode(err, http.StatusConflict) || IsInvalidStatusCode(err, tusStatusChecksumMismatch) {
+		return ctx == nil || ctx.Err() == nil
+	}
+
+	return isRetryableError(ctx, err)
+}
+
+// Upload sends the remaining content of r from upload.Offset in chunks. On a
+// failure the offset is fetched from the server and the upload continues.
+func (t *TusClient) Upload(ctx context.Context, upload *TusUpload, r io.ReaderAt) (err error) {
+	failures := 0
+
+	for upload.Offset < upload.Size {
+		err = t.patch(ctx, upload, r)
+
+		if err == nil {
+			failures = 0
+
+			if err = t.save(upload); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !t.isRetryable(ctx, err) {
+			return err
+		}
+
+		failures++
+
+		if failures > t.MaxRetries {
+			return err
+		}
+
+		if err := sleepContext(ctx, retryBackoff(t.RetryBackoff, t.MaxRetryBackoff, failures)); err != nil {
+			return err
+		}
+
+		if _, err := t.Offset(ctx, upload); err != nil && !t.isRetryable(ctx, err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UploadResumable continues the upload stored under fingerprint or creates a
+// new one. The stored state is removed once the upload is complete.
+func (t *TusClient) UploadResumable(ctx context.Context, fingerprint string, r io.ReaderAt, size int64, metadata map[string]string) (upload *TusUpload, err error) {
+	if t.Store == nil {
+		return nil, fmt.Errorf("HTTPClient: tus Store is not set")
+	}
+
+	upload, ok, err := t.Store.Get(fingerprint)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && upload.Size == size {
+		_, err = t.Offset(ctx, upload)
+
+		if IsInvalidStatusCode(err, http.StatusNotFound) || IsInvalidStatusCode(err, http.StatusGone) || IsInvalidStatusCode(err, http.StatusForbidden) {
+			ok = false
+		} else if err != nil {
+			return nil, err
+		}
+	} else {
+		ok = false
+	}
+
+	if !ok {
+		if upload, err = t.Create(ctx, size, metadata); err != nil {
+			return nil, err
+		}
+
+		upload.Fingerprint = fingerprint
+
+		if err = t.save(upload); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = t.Upload(ctx, upload, r); err != nil {
+		return upload, err
+	}
+
+	if err = t.Store.Delete(fingerprint); err != nil {
+		return upload, err
+	}
+
+	return upload, nil
+}
+
+// Terminate deletes the upload on the server (termination extension).
+func (t *TusClient) Terminate(ctx context.Context, upload *TusUpload) (err error) {
+	_, err = t.Client.Request(&RequestData{
+		Context:        ctx,
+		Method:         "DELETE",
+		FullURL:        upload.URL,
+		Headers:        t.headers(),
+		ExpectedStatus: []int{http.StatusNoContent},
+		RespConsume:    true,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if t.Store != nil && upload.Fingerprint != "" {
+		return t.Store.Delete(upload.Fingerprint)
+	}
+
+	return nil
+}
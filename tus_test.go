@@ -0,0 +1,258 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+type tusServerUpload struct {
+	size     int64
+	metadata string
+	data     []byte
+}
+
+type tusServer struct {
+	mutex     sync.Mutex
+	uploads   map[string]*tusServerUpload
+	nextID    int
+	failPatch func(n int) bool
+	patches   int
+}
+
+func (s *tusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	Expect(r.Header.Get("Tus-Resumable")).To(Equal("1.0.0"))
+
+	if r.Method == "POST" && r.URL.Path == "/files/" {
+		size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		Expect(err).NotTo(HaveOccurred())
+
+		s.nextID++
+		id := fmt.Sprintf("%d", s.nextID)
+		s.uploads[id] = &tusServerUpload{size: size, metadata: r.Header.Get("Upload-Metadata")}
+
+		w.Header().Set("Location", id)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	upload, ok := s.uploads[strings.TrimPrefix(r.URL.Path, "/files/")]
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "HEAD":
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(upload.data)))
+		w.Header().Set("Upload-Length", strconv.FormatInt(upload.size, 10))
+		w.WriteHeader(http.StatusOK)
+
+	case "PATCH":
+		s.patches++
+
+		Expect(r.Header.Get("Content-Type")).To(Equal("application/offset+octet-stream"))
+
+		offset, _ := strconv.Atoi(r.Header.Get("Upload-Offset"))
+
+		if offset != len(upload.data) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		data, _ := io.ReadAll(r.Body)
+
+		if s.failPatch != nil && s.failPatch(s.patches) {
+			// store only a part of the chunk
+			upload.data = append(upload.data, data[:len(data)/2]...)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if checksum := r.Header.Get("Upload-Checksum"); checksum != "" {
+			sum := sha1.Sum(data)
+			if checksum != "sha1 "+base64.StdEncoding.EncodeToString(sum[:]) {
+				w.WriteHeader(460)
+				return
+			}
+		}
+
+		upload.data = append(upload.data, data...)
+
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(upload.data)))
+		w.WriteHeader(http.StatusNoContent)
+
+	case "DELETE":
+		delete(s.uploads, strings.TrimPrefix(r.URL.Path, "/files/"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+var _ = Describe("TusClient", func() {
+	var ts *httptest.Server
+	var server *tusServer
+	var client *HTTPClient
+	var tus *TusClient
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	BeforeEach(func() {
+		server = &tusServer{uploads: map[string]*tusServerUpload{}}
+
+		ts = httptest.NewServer(server)
+
+		u, _ := url.Parse(ts.URL)
+
+		client = New()
+		client.Client = ts.Client()
+		client.BaseURL = u
+
+		tus = NewTusClient(client, "/files/")
+		tus.ChunkSize = 300
+		tus.RetryBackoff = time.Millisecond
+	})
+
+	AfterEach(func() {
+		ts.Close()
+	})
+
+	It("should create and upload in chunks with checksums", func() {
+		tus.ChecksumAlgorithm = "sha1"
+
+		upload, err := tus.Create(context.Background(), int64(len(content)), map[string]string{"filename": "file.txt", "empty": ""})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(upload.URL).To(Equal(ts.URL + "/files/1"))
+		Expect(server.uploads["1"].metadata).To(Equal("empty,filename ZmlsZS50eHQ="))
+
+		err = tus.Upload(context.Background(), upload, bytes.NewReader(content))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(upload.Offset).To(Equal(int64(len(content))))
+		Expect(server.uploads["1"].data).To(Equal(content))
+		Expect(server.patches).To(Equal(4))
+	})
+
+	It("should not compress chunks", func() {
+		client.SetReqCompression(CompressionGzip, 0)
+		tus.ChecksumAlgorithm = "sha1"
+
+		upload, err := tus.Create(context.Background(), int64(len(content)), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = tus.Upload(context.Background(), upload, bytes.NewReader(content))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(server.uploads["1"].data).To(Equal(content))
+	})
+
+	It("should resume from server offset after a failure", func() {
+		server.failPatch = func(n int) bool {
+			return n == 2
+		}
+
+		upload, err := tus.Create(context.Background(), int64(len(content)), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = tus.Upload(context.Background(), upload, bytes.NewReader(content))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(server.uploads["1"].data).To(Equal(content))
+	})
+
+	It("should fail after max retries", func() {
+		server.failPatch = func(n int) bool {
+			return true
+		}
+		tus.MaxRetries = 2
+
+		upload, err := tus.Create(context.Background(), int64(len(content)), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = tus.Upload(context.Background(), upload, bytes.NewReader(content))
+		Expect(IsInvalidStatusCode(err, http.StatusInternalServerError)).To(BeTrue())
+	})
+
+	It("should resume upload from persisted state", func() {
+		dir := GinkgoT().TempDir()
+		tus.Store = NewTusFileStore(dir)
+
+		server.failPatch = func(n int) bool {
+			return n == 2
+		}
+		tus.MaxRetries = 0
+
+		_, err := tus.UploadResumable(context.Background(), "file.txt", bytes.NewReader(content), int64(len(content)), nil)
+		Expect(err).To(HaveOccurred())
+
+		stored, ok, err := tus.Store.Get("file.txt")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(stored.Offset).To(Equal(int64(300)))
+
+		// a new client, e.g. after a restart
+		tus = NewTusClient(client, "/files/")
+		tus.Store = NewTusFileStore(dir)
+
+		upload, err := tus.UploadResumable(context.Background(), "file.txt", bytes.NewReader(content), int64(len(content)), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(upload.URL).To(Equal(stored.URL))
+		Expect(server.uploads).To(HaveLen(1))
+		Expect(server.uploads["1"].data).To(Equal(content))
+
+		_, ok, err = tus.Store.Get("file.txt")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should create a new upload if stored upload is gone", func() {
+		tus.Store = NewTusMemoryStore()
+		tus.Store.Set(&TusUpload{
+			URL:         ts.URL + "/files/missing",
+			Size:        int64(len(content)),
+			Offset:      100,
+			Fingerprint: "file.txt",
+		})
+
+		upload, err := tus.UploadResumable(context.Background(), "file.txt", bytes.NewReader(content), int64(len(content)), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(upload.URL).To(Equal(ts.URL + "/files/1"))
+		Expect(server.uploads["1"].data).To(Equal(content))
+	})
+
+	It("should terminate upload", func() {
+		tus.Store = NewTusMemoryStore()
+
+		upload, err := tus.Create(context.Background(), int64(len(content)), nil)
+		Expect(err).NotTo(HaveOccurred())
+		upload.Fingerprint = "file.txt"
+		Expect(tus.Store.Set(upload)).To(Succeed())
+
+		Expect(tus.Terminate(context.Background(), upload)).To(Succeed())
+		Expect(server.uploads).To(BeEmpty())
+
+		_, ok, err := tus.Store.Get("file.txt")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+
+		_, err = tus.Offset(context.Background(), upload)
+		Expect(IsInvalidStatusCode(err, http.StatusNotFound)).To(BeTrue())
+	})
+})
@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// UnixSocketHostHeader is the Host header of requests to unix sockets.
+	UnixSocketHostHeader = "localhost"
+
+	unixSocketHostSuffix = ".unix.invalid"
+)
+
+// UnixSocketURL returns a http+unix base URL for the socket. url.Parse does
+// not accept the escaped socket path in http+unix://%2Fvar%2Frun%2Fapp.sock.
+func UnixSocketURL(socketPath string, basePath string) *url.URL {
+	return &url.URL{
+		Scheme: "http+unix",
+		Host:   socketPath,
+		Path:   basePath,
+	}
+}
+
+// unixSocketPath returns the socket path and base path of unix:///app.sock
+// (the whole path is the socket) and http+unix://%2Fapp.sock/base/path URLs.
+func unixSocketPath(u *url.URL) (socketPath string, basePath string, ok bool) {
+	switch u.Scheme {
+	case "unix":
+		return u.Host + u.Path, "/", true
+
+	case "http+unix":
+		socketPath, err := url.PathUnescape(u.Host)
+
+		if err != nil {
+			socketPath = u.Host
+		}
+
+		basePath = u.Path
+
+		if basePath == "" {
+			basePath = "/"
+		}
+
+		return socketPath, basePath, true
+	}
+
+	return "", "", false
+}
+
+// unixSocketHost encodes the socket path as the request host. The host is
+// unique per socket so that connections to different sockets are not shared
+// by the transport and it never resolves if the transport does not use
+// DialContext.
+func unixSocketHost(socketPath string) string {
+	return hex.EncodeToString([]byte(socketPath)) + unixSocketHostSuffix
+}
+
+func isUnixSocketHost(host string) bool {
+	return strings.HasSuffix(host, unixSocketHostSuffix)
+}
+
+func unixSocketFromAddr(addr string) (socketPath string, ok bool) {
+	host, _, err := net.SplitHostPort(addr)
+
+	if err != nil || !isUnixSocketHost(host) {
+		return "", false
+	}
+
+	b, err := hex.DecodeString(strings.TrimSuffix(host, unixSocketHostSuffix))
+
+	if err != nil {
+		return "", false
+	}
+
+	return string(b), true
+}
+
+type dialContextFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+// unixDialContext dials the unix socket for unix socket hosts and uses dial
+// for other addresses.
+func unixDialContext(dial dialContextFunc) dialContextFunc {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		if socketPath, ok := unixSocketFromAddr(addr); ok {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		}
+
+		return dial(ctx, network, addr)
+	}
+}
+
+var defaultDialer = &net.Dialer{}
+
+// DialContext is the dial function of HttpTransport and InsecureHttpTransport.
+// It supports the unix socket base URLs (see HTTPClient.BaseURL), set it as
+// http.Transport.DialContext to use them with a custom transport.
+var DialContext = unixDialContext(defaultDialer.DialContext)
+
+// unixSocketProxy does not use a proxy for unix socket hosts.
+func unixSocketProxy(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if isUnixSocketHost(req.URL.Host) {
+			return nil, nil
+		}
+
+		return proxy(req)
+	}
+}
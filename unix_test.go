@@ -0,0 +1,147 @@
+package httpclient_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/koofr/go-httpclient"
+)
+
+var _ = Describe("Unix sockets", func() {
+	var dir string
+
+	// listen starts a server on a unix socket that responds with the name,
+	// host and request URI
+	listen := func(name string) string {
+		socketPath := filepath.Join(dir, name+".sock")
+
+		listener, err := net.Listen("unix", socketPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		server := &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "%s %s %s", name, r.Host, r.RequestURI)
+			}),
+		}
+
+		go server.Serve(listener)
+
+		DeferCleanup(server.Close)
+
+		return socketPath
+	}
+
+	get := func(client *HTTPClient, path string, params url.Values) (string, error) {
+		var body []byte
+
+		_, err := client.Request(&RequestData{
+			Method:         "GET",
+			Path:           path,
+			Params:         params,
+			ExpectedStatus: []int{http.StatusOK},
+			RespValue:      &body,
+		})
+
+		return string(body), err
+	}
+
+	BeforeEach(func() {
+		// socket paths are limited to about 100 characters
+		var err error
+		dir, err = os.MkdirTemp("", "httpclient")
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(os.RemoveAll, dir)
+	})
+
+	It("should request unix:// base URL", func() {
+		socketPath := listen("a")
+
+		client := New()
+		client.BaseURL, _ = url.Parse("unix://" + socketPath)
+
+		Expect(get(client, "/containers/json", url.Values{"all": {"1"}})).To(Equal("a localhost /containers/json?all=1"))
+		Expect(get(client, "info", nil)).To(Equal("a localhost /info"))
+	})
+
+	It("should request http+unix base URL with base path", func() {
+		socketPath := listen("a")
+
+		client := New()
+		client.BaseURL = UnixSocketURL(socketPath, "/v1.41/")
+
+		Expect(get(client, "/file name+1", nil)).To(Equal("a localhost /v1.41/file%20name%2b1"))
+	})
+
+	It("should not share connections between sockets", func() {
+		client := New()
+
+		sockets := []string{listen("a"), listen("b")}
+
+		for i := 0; i < 4; i++ {
+			client.BaseURL = UnixSocketURL(sockets[i%2], "")
+
+			Expect(get(client, "/", nil)).To(Equal([]string{"a", "b"}[i%2] + " localhost /"))
+		}
+	})
+
+	It("should keep HTTP/2 enabled for the default transport", func() {
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.Proto)
+		}))
+		ts.EnableHTTP2 = true
+		ts.StartTLS()
+		defer ts.Close()
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ts.Certificate())
+
+		transport := HttpTransport.Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+
+		client := New()
+		client.Client = &http.Client{Transport: transport}
+		client.BaseURL, _ = url.Parse(ts.URL)
+
+		Expect(get(client, "/", nil)).To(Equal("HTTP/2.0"))
+	})
+
+	It("should use unix sockets and custom dialer with transport options", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "tcp %s %s", r.Host, r.RequestURI)
+		}))
+		defer ts.Close()
+
+		dialed := []string{}
+
+		client, err := NewWithTransport(TransportOptions{
+			DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+				dialed = append(dialed, addr)
+				var d net.Dialer
+				return d.DialContext(ctx, network, ts.Listener.Addr().String())
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		client.BaseURL, _ = url.Parse("http://service.internal:8080")
+
+		Expect(get(client, "/x", nil)).To(Equal("tcp service.internal:8080 /x"))
+		Expect(dialed).To(Equal([]string{"service.internal:8080"}))
+
+		client.BaseURL, _ = url.Parse("unix://" + listen("a"))
+
+		Expect(get(client, "/y", nil)).To(Equal("a localhost /y"))
+		Expect(dialed).To(HaveLen(1))
+	})
+})
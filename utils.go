@@ -12,3 +12,19 @@ func EscapePath(path string) string {
 
 	return strings.Replace(u.String(), "+", "%2b", -1)
 }
+
+// parseRequestURL converts the opaque URL set by buildURL to a regular URL with
+// Path, e.g. to resolve references against it.
+func parseRequestURL(u *url.URL) (*url.URL, error) {
+	if u.Opaque == "" {
+		return u, nil
+	}
+
+	s := u.Scheme + "://" + u.Host + u.Opaque
+
+	if u.RawQuery != "" {
+		s += "?" + u.RawQuery
+	}
+
+	return url.Parse(s)
+}